@@ -0,0 +1,140 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/fatih/color"
+)
+
+// resolveAlias looks name up in the aliases of the registry. It reports
+// whether name is an alias, failing when the template it stands for is no
+// longer installed.
+func resolveAlias(installed []string, name string) (string, bool, error) {
+	registry, err := loadRegistry()
+	if err != nil {
+		return "", false, err
+	}
+	id, ok := registry.Aliases[name]
+	if !ok {
+		return "", false, nil
+	}
+	if !slices.Contains(installed, id) {
+		return "", true, fmt.Errorf(color.RedString("Error: alias '%s' stands for '%s', which is not installed", name, id))
+	}
+	return id, true, nil
+}
+
+// setAlias makes alias stand for the installed template name refers to.
+func setAlias(ctx context.Context, alias string, name string) error {
+	if !isValidTemplateName(alias) {
+		return fmt.Errorf(color.RedString("Error: invalid alias: %s", alias))
+	}
+
+	unlock, err := lockTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	installed, err := installedTemplates()
+	if err != nil {
+		return err
+	}
+	if slices.Contains(installed, alias) {
+		return fmt.Errorf(color.RedString("Error: '%s' is the name of an installed template", alias))
+	}
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	// An alias may be given for another one, but always stands for a template
+	id, err := resolveTemplate(installed, name)
+	if err != nil {
+		return err
+	}
+
+	if registry.Aliases == nil {
+		registry.Aliases = map[string]string{}
+	}
+	registry.Aliases[alias] = id
+	if err := registry.save(); err != nil {
+		return err
+	}
+	fmt.Print(color.GreenString("Alias '%s' now stands for '%s'\n", alias, id))
+	return nil
+}
+
+// deleteAlias removes an alias, leaving the template it stood for alone.
+func deleteAlias(ctx context.Context, alias string) error {
+	unlock, err := lockTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	if _, ok := registry.Aliases[alias]; !ok {
+		return fmt.Errorf(color.RedString("Error: alias '%s' not found", alias))
+	}
+	delete(registry.Aliases, alias)
+	if err := registry.save(); err != nil {
+		return err
+	}
+	fmt.Print(color.GreenString("Alias '%s' deleted\n", alias))
+	return nil
+}
+
+// listAliases prints every alias and the template it stands for.
+func listAliases() error {
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	if len(registry.Aliases) == 0 {
+		fmt.Println(color.YellowString("No aliases. Add one with 'figo templates alias <alias> <template>'."))
+		return nil
+	}
+
+	installed, err := installedTemplates()
+	if err != nil {
+		return err
+	}
+
+	aliases := make([]string, 0, len(registry.Aliases))
+	for alias := range registry.Aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	rows := make([][]string, 0, len(aliases))
+	for _, alias := range aliases {
+		id := registry.Aliases[alias]
+		if !slices.Contains(installed, id) {
+			id += color.RedString(" (not installed)")
+		}
+		rows = append(rows, []string{alias, id})
+	}
+	printTable([]string{"ALIAS", "TEMPLATE"}, rows)
+	return nil
+}
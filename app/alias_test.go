@@ -0,0 +1,57 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"os"
+	"testing"
+)
+
+func TestResolveAlias(t *testing.T) {
+	installed := []string{"github.com/acme/templates/api"}
+
+	tests := []struct {
+		name     string
+		registry string
+		lookup   string
+		want     string
+		wantErr  bool
+	}{
+		{name: "alias", registry: `{"version": 2, "aliases": {"svc": "github.com/acme/templates/api"}}`, lookup: "svc", want: installed[0]},
+		{name: "not an alias", registry: `{"version": 2}`, lookup: "api", want: installed[0]},
+		{name: "alias of a removed template", registry: `{"version": 2, "aliases": {"svc": "github.com/acme/templates/cli"}}`, lookup: "svc", wantErr: true},
+		{name: "unreadable registry", registry: `{"version": `, lookup: "api", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestHome(t)
+			if err := os.MkdirAll(getDefaultDirectory(""), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(registryPath(), []byte(tt.registry), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := resolveTemplate(installed, tt.lookup)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
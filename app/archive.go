@@ -0,0 +1,233 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"cmp"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Kinds of git hosts whose repository archives figo can download.
+const (
+	archiveGitHub = "github"
+	archiveGitLab = "gitlab"
+	archiveGitea  = "gitea"
+)
+
+// knownArchiveHosts are the public hosts whose kind doesn't need configuring.
+var knownArchiveHosts = map[string]string{
+	"github.com":   archiveGitHub,
+	"gitlab.com":   archiveGitLab,
+	"codeberg.org": archiveGitea,
+	"gitea.com":    archiveGitea,
+}
+
+// repositoryArchive is a tar.gz download of a repository at a ref.
+type repositoryArchive struct {
+	url    string
+	header http.Header
+}
+
+// archiveFor returns where to download the repository at repoURL as an
+// archive, for hosts known or configured to offer archive downloads.
+func archiveFor(ctx context.Context, repoURL string, ref string) (*repositoryArchive, bool) {
+	parsedURL, err := url.Parse(repoURL)
+	if err != nil || (parsedURL.Scheme != "https" && parsedURL.Scheme != "http") {
+		return nil, false
+	}
+
+	host := strings.ToLower(parsedURL.Hostname())
+	kind, ok := config.Archives[host]
+	if !ok {
+		kind, ok = knownArchiveHosts[host]
+	}
+	if !ok {
+		return nil, false
+	}
+
+	repoPath := strings.TrimSuffix(strings.Trim(parsedURL.Path, "/"), ".git")
+	if strings.Count(repoPath, "/") < 1 {
+		return nil, false
+	}
+	base := parsedURL.Scheme + "://" + parsedURL.Host + "/" + repoPath
+
+	archive := &repositoryArchive{header: http.Header{}}
+	token := config.Auth[host].token(host)
+
+	switch kind {
+	case archiveGitHub:
+		switch {
+		case token != "":
+			// Archive links on the website don't take tokens, so private
+			// repositories are downloaded through the API, which redirects
+			// to a short-lived signed link
+			apiBase := parsedURL.Scheme + "://" + parsedURL.Host + "/api/v3"
+			if host == "github.com" {
+				apiBase = "https://api.github.com"
+			}
+			archive.url = apiBase + "/repos/" + repoPath + "/tarball"
+			if ref != "" {
+				archive.url += "/" + ref
+			}
+			archive.header.Set("Authorization", "Bearer "+token)
+			archive.header.Set("Accept", "application/vnd.github+json")
+		case host == "github.com":
+			archive.url = "https://codeload.github.com/" + repoPath + "/tar.gz/" + cmp.Or(ref, "HEAD")
+		default:
+			archive.url = base + "/archive/" + cmp.Or(ref, "HEAD") + ".tar.gz"
+		}
+	case archiveGitLab:
+		ref = cmp.Or(ref, "HEAD")
+		archive.url = base + "/-/archive/" + ref + "/" + path.Base(repoPath) + "-" + strings.ReplaceAll(ref, "/", "-") + ".tar.gz"
+		if token != "" {
+			archive.header.Set("PRIVATE-TOKEN", token)
+		}
+	case archiveGitea:
+		if token != "" {
+			archive.header.Set("Authorization", "token "+token)
+		}
+		if ref == "" {
+			// Gitea has no archive of HEAD, so ask for the default branch
+			if ref, err = giteaDefaultBranch(ctx, parsedURL, repoPath, archive.header); err != nil {
+				return nil, false
+			}
+		}
+		archive.url = base + "/archive/" + ref + ".tar.gz"
+	default:
+		return nil, false
+	}
+	return archive, true
+}
+
+// giteaDefaultBranch asks the Gitea API for the default branch of a repository.
+func giteaDefaultBranch(ctx context.Context, repoURL *url.URL, repoPath string, header http.Header) (string, error) {
+	apiURL := repoURL.Scheme + "://" + repoURL.Host + "/api/v1/repos/" + repoPath
+
+	var repo struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	err := httpDownload(ctx, apiURL, header, "archive download", func(body io.Reader) error {
+		return json.NewDecoder(body).Decode(&repo)
+	})
+	if err != nil {
+		return "", err
+	}
+	if repo.DefaultBranch == "" {
+		return "", fmt.Errorf("%s has no default branch", apiURL)
+	}
+	return repo.DefaultBranch, nil
+}
+
+// downloadArchive downloads and unpacks the archive of the repository at
+// repoURL into a new temporary directory, keeping only subdirectory when
+// one is given. The revision is known when the archive records its commit,
+// as archives made by git archive do.
+func downloadArchive(ctx context.Context, archive *repositoryArchive, subdirectory string) (string, string, error) {
+	repoDir, err := os.MkdirTemp("", "figo-repo-*")
+	if err != nil {
+		return "", "", fmt.Errorf(color.RedString("Error: creating temporary directory: %v", err))
+	}
+
+	var revision string
+	err = httpDownload(ctx, archive.url, archive.header, "archive download", func(body io.Reader) error {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return fmt.Errorf(color.RedString("Error: reading archive: %v", err))
+		}
+		defer gz.Close()
+
+		// Archives hold a single top-level directory named after the
+		// repository and ref, which is dropped
+		revision, err = extractTarEntries(gz, repoDir, func(name string) string {
+			_, name, _ = strings.Cut(name, "/")
+			if subdirectory != "" && name != subdirectory && !strings.HasPrefix(name, subdirectory+"/") {
+				return ""
+			}
+			return name
+		})
+		return err
+	})
+	if err != nil {
+		os.RemoveAll(repoDir)
+		return "", "", err
+	}
+	return repoDir, revision, nil
+}
+
+// authHeaders are the request headers that carry credentials for a host.
+var authHeaders = []string{"Authorization", "PRIVATE-TOKEN"}
+
+// httpClient follows redirects like the default client, but never hands the
+// credentials meant for one host to another: archive downloads commonly
+// redirect to storage or signed links on other hosts.
+var httpClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if !strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+			for _, key := range authHeaders {
+				req.Header.Del(key)
+			}
+		}
+		return nil
+	},
+}
+
+// httpDownload requests url and hands the body of a successful response to
+// read, giving up after the timeout for step.
+func httpDownload(ctx context.Context, url string, header http.Header, step string, read func(io.Reader) error) error {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout(step))
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("User-Agent", "figo/"+appVersion)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return read(resp.Body)
+}
+
+// gitInstalled reports whether git can be run.
+func gitInstalled() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestArchiveFor(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		ref        string
+		auth       map[string]hostAuth
+		archives   map[string]string
+		wantURL    string
+		wantHeader map[string]string
+	}{
+		{
+			name:    "github",
+			url:     "https://github.com/acme/templates.git",
+			wantURL: "https://codeload.github.com/acme/templates/tar.gz/HEAD",
+		},
+		{
+			name:       "github with a token",
+			url:        "https://github.com/acme/templates",
+			ref:        "v1.0.0",
+			auth:       map[string]hostAuth{"github.com": {Token: "secret"}},
+			wantURL:    "https://api.github.com/repos/acme/templates/tarball/v1.0.0",
+			wantHeader: map[string]string{"Authorization": "Bearer secret"},
+		},
+		{
+			name:       "github with a token at the default branch",
+			url:        "https://github.com/acme/templates",
+			auth:       map[string]hostAuth{"github.com": {Token: "secret"}},
+			wantURL:    "https://api.github.com/repos/acme/templates/tarball",
+			wantHeader: map[string]string{"Authorization": "Bearer secret"},
+		},
+		{
+			name:     "github enterprise",
+			url:      "https://git.acme.dev/platform/templates",
+			ref:      "main",
+			archives: map[string]string{"git.acme.dev": archiveGitHub},
+			wantURL:  "https://git.acme.dev/platform/templates/archive/main.tar.gz",
+		},
+		{
+			name:       "github enterprise with a token",
+			url:        "https://git.acme.dev/platform/templates",
+			ref:        "main",
+			auth:       map[string]hostAuth{"git.acme.dev": {Token: "secret"}},
+			archives:   map[string]string{"git.acme.dev": archiveGitHub},
+			wantURL:    "https://git.acme.dev/api/v3/repos/platform/templates/tarball/main",
+			wantHeader: map[string]string{"Authorization": "Bearer secret"},
+		},
+		{
+			name:       "gitlab with a token",
+			url:        "https://gitlab.com/acme/templates",
+			ref:        "release/1.0",
+			auth:       map[string]hostAuth{"gitlab.com": {Token: "secret"}},
+			wantURL:    "https://gitlab.com/acme/templates/-/archive/release/1.0/templates-release-1.0.tar.gz",
+			wantHeader: map[string]string{"PRIVATE-TOKEN": "secret"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FIGO_TOKEN_GITHUB_COM", "")
+			previous := config
+			config = &figoConfig{Auth: tt.auth, Archives: tt.archives}
+			t.Cleanup(func() { config = previous })
+
+			archive, ok := archiveFor(context.Background(), tt.url, tt.ref)
+			if !ok {
+				t.Fatalf("archiveFor() found no archive")
+			}
+			if archive.url != tt.wantURL {
+				t.Errorf("archiveFor() url = %q, want %q", archive.url, tt.wantURL)
+			}
+			for _, key := range authHeaders {
+				if got := archive.header.Get(key); got != tt.wantHeader[key] {
+					t.Errorf("archiveFor() header %s = %q, want %q", key, got, tt.wantHeader[key])
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPDownloadRedirect(t *testing.T) {
+	var received http.Header
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		io.WriteString(w, "archive")
+	}))
+	defer storage.Close()
+
+	var forge *httptest.Server
+	forge = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/same-host":
+			http.Redirect(w, r, forge.URL+"/archive", http.StatusFound)
+		case "/other-host":
+			http.Redirect(w, r, storage.URL+"/archive", http.StatusFound)
+		default:
+			received = r.Header.Clone()
+			io.WriteString(w, "archive")
+		}
+	}))
+	defer forge.Close()
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer secret")
+	header.Set("PRIVATE-TOKEN", "secret")
+
+	tests := []struct {
+		path     string
+		wantAuth bool
+	}{
+		{path: "/same-host", wantAuth: true},
+		{path: "/other-host", wantAuth: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			received = nil
+			var body string
+			err := httpDownload(context.Background(), forge.URL+tt.path, header, "archive download", func(r io.Reader) error {
+				data, err := io.ReadAll(r)
+				body = string(data)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("httpDownload() error = %v", err)
+			}
+			if body != "archive" {
+				t.Errorf("httpDownload() body = %q, want %q", body, "archive")
+			}
+			for _, key := range authHeaders {
+				if got := received.Get(key) != ""; got != tt.wantAuth {
+					t.Errorf("redirected request has %s = %v, want %v", key, got, tt.wantAuth)
+				}
+			}
+		})
+	}
+}
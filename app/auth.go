@@ -0,0 +1,161 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// hostAuth is how figo signs in to the git server of a host, from the "auth"
+// section of the config file keyed by host name:
+//
+//	"auth": {
+//	  "github.com": {"token_env": "ACME_GITHUB_TOKEN"},
+//	  "git.acme.dev": {"ssh_key": "~/.ssh/acme_templates"}
+//	}
+//
+// Tokens are used for HTTPS repositories, keys for SSH ones.
+type hostAuth struct {
+	// Username goes with the token; most servers accept any name
+	Username string `json:"username,omitempty"`
+	Token    string `json:"token,omitempty"`
+	// TokenEnv names the environment variable holding the token
+	TokenEnv string `json:"token_env,omitempty"`
+	SSHKey   string `json:"ssh_key,omitempty"`
+}
+
+// credentialHelper hands git the username and password from the environment
+// of the git process, so that tokens never end up in a URL, on the command
+// line or on disk.
+const credentialHelper = `!f() { test "$1" = get || exit 0; echo "username=${FIGO_GIT_USERNAME}"; echo "password=${FIGO_GIT_PASSWORD}"; }; f`
+
+// repositoryHost returns the host of a repository URL, or "" for local paths.
+func repositoryHost(repoURL string) string {
+	if strings.Contains(repoURL, "://") {
+		parsedURL, err := url.Parse(repoURL)
+		if err != nil {
+			return ""
+		}
+		return strings.ToLower(parsedURL.Hostname())
+	}
+	if at, colon := strings.Index(repoURL, "@"), strings.Index(repoURL, ":"); colon > 1 && at < colon && !filepath.IsAbs(repoURL) {
+		return strings.ToLower(repoURL[at+1 : colon])
+	}
+	return ""
+}
+
+// tokenEnvName returns the environment variable figo reads the token of
+// host from when the config names none, as in FIGO_TOKEN_GITHUB_COM.
+func tokenEnvName(host string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, host)
+	return "FIGO_TOKEN_" + strings.ToUpper(name)
+}
+
+// token returns the token for host, looked up in the configured environment
+// variable, the config itself and finally FIGO_TOKEN_<HOST>.
+func (a hostAuth) token(host string) string {
+	if a.TokenEnv != "" {
+		if token := os.Getenv(a.TokenEnv); token != "" {
+			return token
+		}
+	}
+	return cmp.Or(a.Token, os.Getenv(tokenEnvName(host)))
+}
+
+// gitAuth returns the extra git arguments and environment that fetching
+// from repoURL needs. git never prompts: a missing or wrong credential
+// fails instead of waiting for input that can't be given.
+func gitAuth(repoURL string) (args []string, env []string, err error) {
+	env = []string{"GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never"}
+
+	host := repositoryHost(repoURL)
+	if host == "" {
+		return nil, env, nil
+	}
+	auth := config.Auth[host]
+
+	if parsedURL, err := url.Parse(repoURL); err == nil && (parsedURL.Scheme == "https" || parsedURL.Scheme == "http") {
+		if token := auth.token(host); token != "" {
+			// Only hand the token to this host, and only to this git process
+			prefix := "credential." + parsedURL.Scheme + "://" + parsedURL.Host
+			args = append(args, "-c", prefix+".helper=", "-c", prefix+".helper="+credentialHelper)
+			env = append(env,
+				"FIGO_GIT_USERNAME="+cmp.Or(auth.Username, defaultTokenUsername),
+				"FIGO_GIT_PASSWORD="+token,
+			)
+		}
+		return args, env, nil
+	}
+
+	// SSH
+	switch {
+	case auth.SSHKey != "":
+		key, err := expandHome(auth.SSHKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := os.Stat(key); err != nil {
+			return nil, nil, fmt.Errorf(color.RedString("Error: SSH key for %s: %v", host, err))
+		}
+		env = append(env, "GIT_SSH_COMMAND=ssh -i '"+strings.ReplaceAll(key, "'", `'\''`)+"' -o IdentitiesOnly=yes -o BatchMode=yes")
+	case os.Getenv("GIT_SSH_COMMAND") == "" && os.Getenv("GIT_SSH") == "":
+		env = append(env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	}
+	return args, env, nil
+}
+
+// authError turns a failed fetch that looks like a missing or rejected
+// credential into an error that says how to provide one.
+func authError(repoURL string, err error) error {
+	message := err.Error()
+	httpFailure := strings.Contains(message, "terminal prompts disabled") ||
+		strings.Contains(message, "could not read Username") ||
+		strings.Contains(message, "Authentication failed")
+	sshFailure := strings.Contains(message, "Permission denied (publickey") ||
+		strings.Contains(message, "Host key verification failed")
+
+	host := repositoryHost(repoURL)
+	switch {
+	case httpFailure:
+		return fmt.Errorf(color.RedString("Error: authentication to %s failed; set %s or configure a token for %s in %s\n%v", repoURL, tokenEnvName(host), host, configPath(), err))
+	case sshFailure:
+		return fmt.Errorf(color.RedString("Error: SSH authentication to %s failed; check your SSH agent or configure an ssh_key for %s in %s\n%v", host, host, configPath(), err))
+	}
+	return err
+}
+
+// expandHome replaces a leading ~ in path with the home directory.
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf(color.RedString("Error: locating home directory: %v", err))
+	}
+	return filepath.Join(home, path[1:]), nil
+}
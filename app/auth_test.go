@@ -0,0 +1,149 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTokenEnvName(t *testing.T) {
+	tests := map[string]string{
+		"github.com":        "FIGO_TOKEN_GITHUB_COM",
+		"git.acme.dev":      "FIGO_TOKEN_GIT_ACME_DEV",
+		"gitlab-ci.example": "FIGO_TOKEN_GITLAB_CI_EXAMPLE",
+	}
+	for host, want := range tests {
+		if got := tokenEnvName(host); got != want {
+			t.Errorf("tokenEnvName(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestGitAuth(t *testing.T) {
+	key := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(key, []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		auth     map[string]hostAuth
+		env      map[string]string
+		wantArgs bool
+		wantEnv  []string
+		wantErr  bool
+	}{
+		{
+			name:    "local path",
+			url:     "/srv/templates",
+			env:     map[string]string{"FIGO_TOKEN_GITHUB_COM": "secret"},
+			wantEnv: []string{"GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never"},
+		},
+		{
+			name:    "https without a token",
+			url:     "https://github.com/acme/templates",
+			wantEnv: []string{"GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never"},
+		},
+		{
+			name:     "token from the host variable",
+			url:      "https://github.com/acme/templates",
+			env:      map[string]string{"FIGO_TOKEN_GITHUB_COM": "secret"},
+			wantArgs: true,
+			wantEnv:  []string{"GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never", "FIGO_GIT_USERNAME=" + defaultTokenUsername, "FIGO_GIT_PASSWORD=secret"},
+		},
+		{
+			name:     "token from the configured variable",
+			url:      "https://github.com/acme/templates",
+			auth:     map[string]hostAuth{"github.com": {TokenEnv: "ACME_TOKEN", Username: "ci"}},
+			env:      map[string]string{"ACME_TOKEN": "acme", "FIGO_TOKEN_GITHUB_COM": "secret"},
+			wantArgs: true,
+			wantEnv:  []string{"GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never", "FIGO_GIT_USERNAME=ci", "FIGO_GIT_PASSWORD=acme"},
+		},
+		{
+			name:     "configured variable unset",
+			url:      "https://github.com/acme/templates",
+			auth:     map[string]hostAuth{"github.com": {TokenEnv: "ACME_TOKEN", Token: "config"}},
+			wantArgs: true,
+			wantEnv:  []string{"GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never", "FIGO_GIT_USERNAME=" + defaultTokenUsername, "FIGO_GIT_PASSWORD=config"},
+		},
+		{
+			name:    "token of another host",
+			url:     "https://gitlab.com/acme/templates",
+			env:     map[string]string{"FIGO_TOKEN_GITHUB_COM": "secret"},
+			wantEnv: []string{"GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never"},
+		},
+		{
+			name:    "ssh in batch mode",
+			url:     "git@github.com:acme/templates.git",
+			wantEnv: []string{"GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never", "GIT_SSH_COMMAND=ssh -o BatchMode=yes"},
+		},
+		{
+			name:    "ssh command of the user",
+			url:     "git@github.com:acme/templates.git",
+			env:     map[string]string{"GIT_SSH_COMMAND": "ssh -v"},
+			wantEnv: []string{"GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never"},
+		},
+		{
+			name:    "ssh key",
+			url:     "ssh://git@git.acme.dev/templates.git",
+			auth:    map[string]hostAuth{"git.acme.dev": {SSHKey: key}},
+			wantEnv: []string{"GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never", "GIT_SSH_COMMAND=ssh -i '" + key + "' -o IdentitiesOnly=yes -o BatchMode=yes"},
+		},
+		{
+			name:    "missing ssh key",
+			url:     "ssh://git@git.acme.dev/templates.git",
+			auth:    map[string]hostAuth{"git.acme.dev": {SSHKey: key + ".missing"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"FIGO_TOKEN_GITHUB_COM", "ACME_TOKEN", "GIT_SSH_COMMAND", "GIT_SSH"} {
+				t.Setenv(name, tt.env[name])
+			}
+			previous := config
+			config = &figoConfig{Auth: tt.auth}
+			t.Cleanup(func() { config = previous })
+
+			args, env, err := gitAuth(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("gitAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !slices.Equal(env, tt.wantEnv) {
+				t.Errorf("gitAuth() env = %q, want %q", env, tt.wantEnv)
+			}
+			if (len(args) > 0) != tt.wantArgs {
+				t.Errorf("gitAuth() args = %q, want args %v", args, tt.wantArgs)
+			}
+			// Tokens only ever reach git through its environment
+			for _, arg := range args {
+				for _, token := range []string{"secret", "acme", "config"} {
+					if strings.Contains(arg, token) {
+						t.Errorf("gitAuth() passes the token in argument %q", arg)
+					}
+				}
+			}
+		})
+	}
+}
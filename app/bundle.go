@@ -0,0 +1,415 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// A bundle is a tar.gz holding installed templates for moving them to
+// machines that can't reach their sources. bundle.json describes the
+// templates, whose files are stored under templates/<id>/.
+
+// templateBundle is the bundle.json file of a bundle.
+type templateBundle struct {
+	Version   int                       `json:"version"`
+	CreatedAt time.Time                 `json:"created_at"`
+	Templates map[string]*registryEntry `json:"templates"`
+}
+
+// exportTemplates writes the named templates, or all of them when no names
+// are given, to a bundle at output.
+func exportTemplates(ctx context.Context, names []string, output string) error {
+	unlock, err := lockTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	installed, err := installedTemplates()
+	if err != nil {
+		return err
+	}
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		names = installed
+	}
+	if len(names) == 0 {
+		return fmt.Errorf(color.RedString("Error: no templates to export"))
+	}
+
+	bundle := templateBundle{Version: bundleVersion, CreatedAt: time.Now().UTC(), Templates: map[string]*registryEntry{}}
+	for _, name := range names {
+		id, err := resolveTemplate(installed, name)
+		if err != nil {
+			return err
+		}
+
+		entry, ok := registry.Templates[id]
+		if !ok {
+			return fmt.Errorf(color.RedString("Error: template '%s' is not recorded in the registry and can't be exported", id))
+		}
+		if entry.editable() {
+			// Bundle the template as it is now
+			files, err := templateFiles(templatePath(id))
+			if err != nil {
+				return err
+			}
+			bundled := *entry
+			bundled.Hash, bundled.Files = digestFiles(files), files
+			entry = &bundled
+		} else {
+			changes, err := checkTemplate(id, entry)
+			if err != nil {
+				return err
+			}
+			if !changes.empty() {
+				return fmt.Errorf(color.RedString("Error: template '%s' was modified locally:\n%s\nRestore it with 'figo templates update --force %s' before exporting it", id, changes, id))
+			}
+		}
+		bundle.Templates[id] = entry
+	}
+
+	// Write next to the output and move into place once complete
+	file, err := os.CreateTemp(filepath.Dir(output), ".figo-bundle-*")
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: creating bundle: %v", err))
+	}
+	defer os.Remove(file.Name())
+
+	if err := writeBundle(ctx, file, &bundle); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf(color.RedString("Error: writing bundle: %v", err))
+	}
+	if err := os.Rename(file.Name(), output); err != nil {
+		return fmt.Errorf(color.RedString("Error: writing bundle: %v", err))
+	}
+
+	fmt.Print(color.GreenString("Exported %d template(s) to %s\n", len(bundle.Templates), output))
+	return nil
+}
+
+func writeBundle(ctx context.Context, w io.Writer, bundle *templateBundle) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: encoding bundle: %v", err))
+	}
+	header := &tar.Header{Name: bundleManifestName, Mode: 0644, Size: int64(len(data)), ModTime: bundle.CreatedAt}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf(color.RedString("Error: writing bundle: %v", err))
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf(color.RedString("Error: writing bundle: %v", err))
+	}
+
+	ids := make([]string, 0, len(bundle.Templates))
+	for id := range bundle.Templates {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := addTemplateToBundle(tw, id); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf(color.RedString("Error: writing bundle: %v", err))
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf(color.RedString("Error: writing bundle: %v", err))
+	}
+	return nil
+}
+
+func addTemplateToBundle(tw *tar.Writer, id string) error {
+	root := templatePath(id)
+	return filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf(color.RedString("Error: accessing path %q: %v", filePath, err))
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return fmt.Errorf(color.RedString("Error: writing bundle: %v", err))
+		}
+		header.Name = path.Join(bundleTemplatesDir, id, filepath.ToSlash(relPath))
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf(color.RedString("Error: writing bundle: %v", err))
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		file, err := os.Open(filePath)
+		if err != nil {
+			return fmt.Errorf(color.RedString("Error: reading %q: %v", filePath, err))
+		}
+		defer file.Close()
+		if _, err := io.Copy(tw, file); err != nil {
+			return fmt.Errorf(color.RedString("Error: writing bundle: %v", err))
+		}
+		return nil
+	})
+}
+
+// importTemplates installs the templates of the bundle at bundlePath.
+// Every template is checked against the hash recorded for it, and against
+// the signature policy, before anything is extracted; name collisions are
+// handled as for add-templates.
+func importTemplates(ctx context.Context, bundlePath string, opts addOptions) error {
+	bundle, signers, err := scanBundle(bundlePath)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(bundlePath)
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: opening bundle: %v", err))
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: reading bundle: %v", err))
+	}
+
+	bundleDir, err := os.MkdirTemp("", "figo-bundle-*")
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: creating temporary directory: %v", err))
+	}
+	defer os.RemoveAll(bundleDir)
+
+	if err := extractTar(gz, bundleDir); err != nil {
+		return err
+	}
+
+	var candidates []templateCandidate
+	for id, entry := range bundle.Templates {
+		// The bundle could have changed since it was checked
+		dir := filepath.Join(bundleDir, bundleTemplatesDir, filepath.FromSlash(id))
+		hash, err := hashTemplate(dir)
+		if err != nil {
+			return err
+		}
+		if hash != entry.Hash {
+			return fmt.Errorf(color.RedString("Error: template '%s' in the bundle does not match its recorded hash", id))
+		}
+		candidates = append(candidates, templateCandidate{name: id, dir: dir, subdirectory: entry.Subdirectory, signer: signers[id]})
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].name < candidates[j].name })
+
+	unlock, err := lockTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Remember which bundle entry each candidate came from, as renaming
+	// changes its name
+	entries := map[string]*registryEntry{}
+	for _, candidate := range candidates {
+		entries[candidate.dir] = bundle.Templates[candidate.name]
+	}
+
+	candidates, err = resolveCollisions(ctx, candidates, opts)
+	if err != nil {
+		return err
+	}
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	var installErr error
+	for _, candidate := range candidates {
+		entry := entries[candidate.dir]
+		source := templateSource{URL: entry.Source, Ref: entry.Ref, Revision: entry.Revision, Tag: entry.Tag}
+		if installErr = installTemplates(ctx, []templateCandidate{candidate}, source, registry); installErr != nil {
+			break
+		}
+	}
+
+	// Record whatever was installed, even if installation stopped part way
+	if err := registry.save(); err != nil {
+		return err
+	}
+	if installErr != nil {
+		return fmt.Errorf(color.RedString("Error: importing templates: %v", installErr))
+	}
+	return nil
+}
+
+// scanBundle reads the bundle at bundlePath without extracting it. It checks
+// that the bundle holds nothing but the files of its templates, that each
+// template matches its recorded hash, and that it passes the signature
+// policy. It returns the bundle description and the signer of each template.
+func scanBundle(bundlePath string) (*templateBundle, map[string]string, error) {
+	file, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf(color.RedString("Error: opening bundle: %v", err))
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf(color.RedString("Error: reading bundle: %v", err))
+	}
+	reader := tar.NewReader(gz)
+
+	// bundle.json comes first, so that the rest can be checked against it
+	header, err := reader.Next()
+	if err != nil || header.Name != bundleManifestName || header.Typeflag != tar.TypeReg {
+		return nil, nil, fmt.Errorf(color.RedString("Error: %s is not a figo bundle", bundlePath))
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, maxBundleManifestSize))
+	if err != nil {
+		return nil, nil, fmt.Errorf(color.RedString("Error: reading bundle: %v", err))
+	}
+	var bundle templateBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, nil, fmt.Errorf(color.RedString("Error: parsing bundle: %v", err))
+	}
+	if bundle.Version > bundleVersion {
+		return nil, nil, fmt.Errorf(color.RedString("Error: bundle version %d is newer than this figo supports", bundle.Version))
+	}
+
+	files := map[string]map[string]string{}
+	signatures := map[string][]byte{}
+	for id, entry := range bundle.Templates {
+		if !isValidTemplateID(id) || entry == nil {
+			return nil, nil, fmt.Errorf(color.RedString("Error: bundle holds a template with invalid name %q", id))
+		}
+		files[id] = map[string]string{}
+	}
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf(color.RedString("Error: reading bundle: %v", err))
+		}
+
+		name := path.Clean(strings.TrimSuffix(header.Name, "/"))
+		id, relPath := bundleTemplateOf(&bundle, name)
+		switch {
+		case header.Typeflag == tar.TypeDir:
+			continue
+		case header.Typeflag != tar.TypeReg:
+			return nil, nil, fmt.Errorf(color.RedString("Error: bundle entry %q is not a regular file or directory", header.Name))
+		case id == "" || relPath == "":
+			return nil, nil, fmt.Errorf(color.RedString("Error: bundle entry %q does not belong to any of its templates", header.Name))
+		case relPath == signatureFileName:
+			if signatures[id], err = io.ReadAll(io.LimitReader(reader, maxSignatureSize)); err != nil {
+				return nil, nil, fmt.Errorf(color.RedString("Error: reading bundle: %v", err))
+			}
+			continue
+		case slices.ContainsFunc(strings.Split(relPath, "/"), shouldSkipDir):
+			// Left out of hashes, as when installing
+			continue
+		}
+
+		digest := sha256.New()
+		if _, err := io.Copy(digest, reader); err != nil {
+			return nil, nil, fmt.Errorf(color.RedString("Error: reading bundle: %v", err))
+		}
+		files[id][relPath] = hex.EncodeToString(digest.Sum(nil))
+	}
+
+	signers := map[string]string{}
+	for id, entry := range bundle.Templates {
+		hash := digestFiles(files[id])
+		if hash != entry.Hash {
+			return nil, nil, fmt.Errorf(color.RedString("Error: template '%s' in the bundle does not match its recorded hash", id))
+		}
+		if signers[id], err = checkSignatureOf(id, signatures[id], hash); err != nil {
+			return nil, nil, err
+		}
+	}
+	return &bundle, signers, nil
+}
+
+// bundleTemplateOf returns the template of bundle that the entry at name
+// belongs to, and its path within the template.
+func bundleTemplateOf(bundle *templateBundle, name string) (string, string) {
+	best := ""
+	for id := range bundle.Templates {
+		prefix := bundleTemplatesDir + "/" + id
+		if (name == prefix || strings.HasPrefix(name, prefix+"/")) && len(id) > len(best) {
+			best = id
+		}
+	}
+	if best == "" {
+		return "", ""
+	}
+	return best, strings.TrimPrefix(strings.TrimPrefix(name, bundleTemplatesDir+"/"+best), "/")
+}
+
+// isValidTemplateID reports whether id is a usable template identifier:
+// a relative path of non-hidden segments.
+func isValidTemplateID(id string) bool {
+	if id == "" || !filepath.IsLocal(filepath.FromSlash(id)) {
+		return false
+	}
+	for _, segment := range strings.Split(id, "/") {
+		if segment == "" || strings.HasPrefix(segment, ".") {
+			return false
+		}
+	}
+	return true
+}
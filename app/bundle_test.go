@@ -0,0 +1,125 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestBundle writes a bundle holding one template, whose recorded hash
+// is made over files, and whose archive holds entries.
+func writeTestBundle(t *testing.T, id string, files map[string]string, entries []tarEntry) string {
+	t.Helper()
+
+	hashes := map[string]string{}
+	for name, content := range files {
+		sum := sha256.Sum256([]byte(content))
+		hashes[name] = hex.EncodeToString(sum[:])
+	}
+	manifest, err := json.Marshal(templateBundle{
+		Version:   bundleVersion,
+		Templates: map[string]*registryEntry{id: {Hash: digestFiles(hashes), Files: hashes}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundlePath := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	file, err := os.Create(bundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	archive := append([]tarEntry{{name: bundleManifestName, typeflag: tar.TypeReg, content: string(manifest)}}, entries...)
+	if _, err := buildTar(t, archive).WriteTo(gz); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bundlePath
+}
+
+func TestScanBundle(t *testing.T) {
+	const id = "example.com/acme/templates/api"
+	prefix := bundleTemplatesDir + "/" + id + "/"
+	files := map[string]string{"go.mod": "module api\n", "main.go": "package main\n"}
+
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		wantErr bool
+	}{
+		{
+			name: "matching bundle",
+			entries: []tarEntry{
+				{name: prefix, typeflag: tar.TypeDir},
+				{name: prefix + "go.mod", typeflag: tar.TypeReg, content: files["go.mod"]},
+				{name: prefix + "main.go", typeflag: tar.TypeReg, content: files["main.go"]},
+			},
+		},
+		{
+			name: "modified file",
+			entries: []tarEntry{
+				{name: prefix + "go.mod", typeflag: tar.TypeReg, content: files["go.mod"]},
+				{name: prefix + "main.go", typeflag: tar.TypeReg, content: "package evil\n"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing file",
+			entries: []tarEntry{
+				{name: prefix + "go.mod", typeflag: tar.TypeReg, content: files["go.mod"]},
+			},
+			wantErr: true,
+		},
+		{
+			name: "symlink",
+			entries: []tarEntry{
+				{name: prefix + "l", typeflag: tar.TypeSymlink, linkname: "/tmp"},
+				{name: prefix + "go.mod", typeflag: tar.TypeReg, content: files["go.mod"]},
+				{name: prefix + "main.go", typeflag: tar.TypeReg, content: files["main.go"]},
+			},
+			wantErr: true,
+		},
+		{
+			name: "entry outside of the templates",
+			entries: []tarEntry{
+				{name: "../evil", typeflag: tar.TypeReg, content: "x"},
+				{name: prefix + "go.mod", typeflag: tar.TypeReg, content: files["go.mod"]},
+				{name: prefix + "main.go", typeflag: tar.TypeReg, content: files["main.go"]},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := scanBundle(writeTestBundle(t, id, files, tt.entries))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("scanBundle() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+)
+
+var (
+	moduleDirectivePattern = regexp.MustCompile(`(?m)^\s*module\s+"?([^"\s]+)"?`)
+	goDirectivePattern     = regexp.MustCompile(`(?m)^\s*go\s+(\S+)`)
+)
+
+// captureOptions are the settings of templates capture.
+type captureOptions struct {
+	// Name is the template's name, the project directory's name by default
+	Name        string
+	Description string
+	Overwrite   bool
+}
+
+// projectTemplatizer turns the files of a project into a template by
+// replacing its module path and name with the Module and ProjectName
+// variables.
+type projectTemplatizer struct {
+	module      string
+	projectName string
+}
+
+// captureProject installs the Go module in dir as a new template, with its
+// module path and project name turned into variables.
+func captureProject(ctx context.Context, dir string, opts captureOptions) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: resolving project directory: %v", err))
+	}
+	if !isGoModule(dir) {
+		return fmt.Errorf(color.RedString("Error: '%s' is not a Go module", dir))
+	}
+	if exists(filepath.Join(dir, manifestFileName)) {
+		return fmt.Errorf(color.RedString("Error: '%s' already has a %s; add it with 'figo add-templates' instead", dir, manifestFileName))
+	}
+
+	goMod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: reading go.mod: %v", err))
+	}
+	match := moduleDirectivePattern.FindSubmatch(goMod)
+	if match == nil {
+		return fmt.Errorf(color.RedString("Error: go.mod of '%s' has no module line", dir))
+	}
+
+	t := projectTemplatizer{module: string(match[1]), projectName: filepath.Base(dir)}
+	name := opts.Name
+	if name == "" {
+		name = t.projectName
+	}
+	if !isValidTemplateName(name) {
+		return fmt.Errorf(color.RedString("Error: invalid template name: %s", name))
+	}
+	id := capturedNamespace + "/" + name
+
+	staging, err := os.MkdirTemp("", "figo-capture-")
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: creating staging directory: %v", err))
+	}
+	defer os.RemoveAll(staging)
+
+	templated, err := t.copy(ctx, dir, staging)
+	if err != nil {
+		return err
+	}
+
+	manifest := templateManifest{
+		Description: cmp.Or(opts.Description, "Captured from "+dir),
+		Variables: []templateVariable{
+			{Name: "Module", Description: "Go module path", Default: t.moduleDefault()},
+		},
+	}
+	if match := goDirectivePattern.FindSubmatch(goMod); match != nil {
+		manifest.Go = string(match[1])
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: encoding manifest: %v", err))
+	}
+	if err := os.WriteFile(filepath.Join(staging, manifestFileName), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf(color.RedString("Error: writing manifest: %v", err))
+	}
+
+	unlock, err := lockTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if templateExists(id) && !opts.Overwrite {
+		return fmt.Errorf(color.RedString("Error: template '%s' already exists; use --overwrite to replace it", id))
+	}
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	// Captured templates have no source to be updated from
+	if err := installTemplates(ctx, []templateCandidate{{name: id, dir: staging}}, templateSource{}, registry); err != nil {
+		return err
+	}
+	if err := registry.save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Replaced module path %s and project name %s in %d file(s)\n", t.module, t.projectName, templated)
+	fmt.Printf("Create a project with 'figo create -n <name> -t %s'\n", name)
+	return nil
+}
+
+// moduleDefault returns the default of the Module variable: the captured
+// module path with its last element standing for the project name, so that
+// projects created from the template each get a module of their own.
+func (t projectTemplatizer) moduleDefault() string {
+	if dir := path.Dir(t.module); dir != "." {
+		return dir + "/{{.ProjectName}}"
+	}
+	return "{{.ProjectName}}"
+}
+
+// copy writes the template made from the project in src into dest, with
+// the same files left out as when installing a template. It returns how
+// many files had the module path or project name replaced.
+func (t projectTemplatizer) copy(ctx context.Context, src string, dest string) (int, error) {
+	templated := 0
+	err := filepath.Walk(src, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf(color.RedString("Error: accessing path %q: %v", filePath, err))
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if filePath == src {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(src, filePath)
+		if err != nil {
+			return fmt.Errorf(color.RedString("Error: getting relative path for %q: %v", filePath, err))
+		}
+		if info.IsDir() && shouldSkipDir(info.Name()) {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && shouldSkipFile(info.Name()) {
+			return nil
+		}
+
+		segments := strings.Split(filepath.ToSlash(relPath), "/")
+		for i, segment := range segments {
+			segments[i], _ = t.replace(segment, false)
+		}
+		target := filepath.Join(dest, filepath.FromSlash(strings.Join(segments, "/")))
+
+		if info.IsDir() {
+			if err := os.MkdirAll(target, info.Mode().Perm()|0700); err != nil {
+				return fmt.Errorf(color.RedString("Error: failed to create directory %q: %v", target, err))
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf(color.RedString("Error: failed to read file %q: %v", filePath, err))
+		}
+
+		// Files already ending in .tmpl are rendered too, so that they come
+		// out of the template as they are
+		if isText(data) {
+			text, changed := t.replace(string(data), true)
+			if changed || strings.HasSuffix(relPath, templateFileSuffix) {
+				data = []byte(text)
+				target += templateFileSuffix
+				if changed {
+					templated++
+				}
+			}
+		}
+
+		if err := os.WriteFile(target, data, info.Mode().Perm()); err != nil {
+			return fmt.Errorf(color.RedString("Error: failed to write file %q: %v", target, err))
+		}
+		return nil
+	})
+	return templated, err
+}
+
+// replace returns text as a template that renders back to it, with the
+// module path and, standing on its own, the project name replaced by
+// variables. inFile is false for path segments, in which only the project
+// name can appear. It reports whether any variable was put in.
+func (t projectTemplatizer) replace(text string, inFile bool) (string, bool) {
+	if !strings.Contains(text, t.projectName) && !(inFile && strings.Contains(text, t.module)) &&
+		!strings.Contains(text, "{{") && !strings.Contains(text, "}}") {
+		return text, false
+	}
+
+	var out strings.Builder
+	changed := false
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		switch {
+		case strings.HasPrefix(rest, "{{"):
+			out.WriteString(`{{"{{"}}`)
+			i += 2
+		case strings.HasPrefix(rest, "}}"):
+			out.WriteString(`{{"}}"}}`)
+			i += 2
+		case inFile && strings.HasPrefix(rest, t.module) && !isModulePathChar(charAt(text, i+len(t.module))):
+			out.WriteString("{{.Module}}")
+			i += len(t.module)
+			changed = true
+		case strings.HasPrefix(rest, t.projectName) && !isWordChar(charBefore(text, i)) && !isWordChar(charAt(text, i+len(t.projectName))):
+			out.WriteString("{{.ProjectName}}")
+			i += len(t.projectName)
+			changed = true
+		default:
+			out.WriteByte(text[i])
+			i++
+		}
+	}
+
+	return out.String(), changed
+}
+
+// isText reports whether data looks like text rather than a binary file.
+func isText(data []byte) bool {
+	return utf8.Valid(data) && !bytes.Contains(data, []byte{0})
+}
+
+func charAt(text string, i int) byte {
+	if i < len(text) {
+		return text[i]
+	}
+	return 0
+}
+
+func charBefore(text string, i int) byte {
+	if i > 0 {
+		return text[i-1]
+	}
+	return 0
+}
+
+func isWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// isModulePathChar reports whether c can continue a module path element, so
+// that github.com/acme/svc is not found in github.com/acme/svc-client.
+func isModulePathChar(c byte) bool {
+	return isWordChar(c) || strings.IndexByte("-._~", c) >= 0
+}
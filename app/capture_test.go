@@ -0,0 +1,153 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProjectTemplatizerReplace(t *testing.T) {
+	templatizer := projectTemplatizer{module: "github.com/acme/svc", projectName: "svc"}
+
+	tests := []struct {
+		text        string
+		inFile      bool
+		want        string
+		wantChanged bool
+	}{
+		{text: "package main\n", inFile: true, want: "package main\n"},
+		{text: `import "github.com/acme/svc/internal/db"`, inFile: true, want: `import "{{.Module}}/internal/db"`, wantChanged: true},
+		{text: "require github.com/acme/svc-client v1.0.0", inFile: true, want: "require github.com/acme/{{.ProjectName}}-client v1.0.0", wantChanged: true},
+		{text: "svc listens on :8080; svcctl does not", inFile: true, want: "{{.ProjectName}} listens on :8080; svcctl does not", wantChanged: true},
+		{text: "Hello, {{.Name}}!", inFile: true, want: `Hello, {{"{{"}}.Name{{"}}"}}!`},
+		{text: "{{svc}}", inFile: true, want: `{{"{{"}}{{.ProjectName}}{{"}}"}}`, wantChanged: true},
+		{text: "github.com/acme/svc", want: "github.com/acme/{{.ProjectName}}", wantChanged: true},
+		{text: "svc", want: "{{.ProjectName}}", wantChanged: true},
+		{text: "svc_test.go", want: "{{.ProjectName}}_test.go", wantChanged: true},
+		{text: "svcctl", want: "svcctl"},
+	}
+
+	for _, tt := range tests {
+		got, changed := templatizer.replace(tt.text, tt.inFile)
+		if got != tt.want || changed != tt.wantChanged {
+			t.Errorf("replace(%q, %v) = %q, %v, want %q, %v", tt.text, tt.inFile, got, changed, tt.want, tt.wantChanged)
+		}
+
+		// The template renders back to the original text
+		rendered, err := renderString("test", got, map[string]any{"Module": "github.com/acme/svc", "ProjectName": "svc"})
+		if err != nil || rendered != tt.text {
+			t.Errorf("replace(%q, %v) renders to %q, %v", tt.text, tt.inFile, rendered, err)
+		}
+	}
+}
+
+func TestCaptureProject(t *testing.T) {
+	useTestHome(t)
+	ctx := context.Background()
+
+	project := filepath.Join(t.TempDir(), "svc")
+	writeTestFiles(t, project, map[string]string{
+		"go.mod":              "module github.com/acme/svc\n\ngo 1.22\n",
+		"main.go":             "package main\n\nimport \"github.com/acme/svc/internal/greet\"\n\nfunc main() { greet.Hello() }\n",
+		"internal/greet/x.go": "package greet\n\n// Hello greets from svc.\nfunc Hello() {}\n",
+		"cmd/svc/main.go":     "package main\n",
+		"web/index.html.tmpl": "<h1>{{.Title}}</h1>\n",
+		"web/page.html":       "<p>{{.Body}}</p>\n",
+		"logo.png":            "\x89PNG\x00svc",
+		".git/HEAD":           "ref: refs/heads/main\n",
+	})
+
+	if err := captureProject(ctx, project, captureOptions{Description: "Service"}); err != nil {
+		t.Fatalf("captureProject() error = %v", err)
+	}
+	id := capturedNamespace + "/svc"
+	templateDir := templatePath(id)
+
+	manifest, err := loadManifest(templateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Description != "Service" || manifest.Go != "1.22" {
+		t.Errorf("captured manifest = %+v", manifest)
+	}
+
+	// A project created from the template under another name gets that name
+	// wherever the original had its own, and everything else as it was
+	values, err := templateValues(manifest, "api", nil, requireVariable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := planProject(templateDir, manifest, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := t.TempDir()
+	if err := renderProject(ctx, jobs, created, values); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"go.mod":              "module github.com/acme/api\n\ngo 1.22\n",
+		"main.go":             "package main\n\nimport \"github.com/acme/api/internal/greet\"\n\nfunc main() { greet.Hello() }\n",
+		"internal/greet/x.go": "package greet\n\n// Hello greets from api.\nfunc Hello() {}\n",
+		"cmd/api/main.go":     "package main\n",
+		"web/index.html.tmpl": "<h1>{{.Title}}</h1>\n",
+		"web/page.html":       "<p>{{.Body}}</p>\n",
+		"logo.png":            "\x89PNG\x00svc",
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(created, filepath.FromSlash(name)))
+		if err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v, want %q", name, data, err, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(created, ".git")); err == nil {
+		t.Error(".git was captured")
+	}
+
+	if err := captureProject(ctx, project, captureOptions{}); err == nil {
+		t.Error("captureProject() replaced an existing template without --overwrite")
+	}
+	if err := captureProject(ctx, project, captureOptions{Overwrite: true}); err != nil {
+		t.Errorf("captureProject() with Overwrite error = %v", err)
+	}
+	if err := captureProject(ctx, t.TempDir(), captureOptions{Name: "empty"}); err == nil {
+		t.Error("captureProject() captured a directory that is not a Go module")
+	}
+}
+
+func TestModuleDefault(t *testing.T) {
+	tests := []struct {
+		module      string
+		projectName string
+		want        string
+	}{
+		{module: "github.com/acme/svc", projectName: "svc", want: "github.com/acme/{{.ProjectName}}"},
+		{module: "github.com/acme/service", projectName: "svc", want: "github.com/acme/{{.ProjectName}}"},
+		{module: "svc", projectName: "svc", want: "{{.ProjectName}}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.module, func(t *testing.T) {
+			templatizer := projectTemplatizer{module: tt.module, projectName: tt.projectName}
+			if got := templatizer.moduleDefault(); got != tt.want {
+				t.Errorf("moduleDefault() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// catalogIndex is a published list of approved templates, fetched over
+// HTTP(S) or from a file:// URL:
+//
+//	{
+//	  "templates": [
+//	    {
+//	      "name": "acme-api",
+//	      "description": "HTTP API service",
+//	      "tags": ["web"],
+//	      "source": "https://github.com/acme/go-templates",
+//	      "dir": "api",
+//	      "ref": "v1.4.0",
+//	      "checksum": "sha256:..."
+//	    }
+//	  ]
+//	}
+type catalogIndex struct {
+	Templates []catalogEntry `json:"templates"`
+}
+
+// catalogEntry is a template in the catalog. Dir is the directory of the
+// template in the repository, empty when the repository is the template,
+// and Checksum the digest figo records for it once installed.
+type catalogEntry struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Source      string   `json:"source"`
+	Dir         string   `json:"dir,omitempty"`
+	Ref         string   `json:"ref,omitempty"`
+	Checksum    string   `json:"checksum,omitempty"`
+}
+
+// catalogURL returns the catalog to use: the one given on the command line
+// or else the one in the config file.
+func catalogURL(flag string) (string, error) {
+	if flag != "" {
+		return flag, nil
+	}
+	if config.Catalog != "" {
+		return config.Catalog, nil
+	}
+	return "", fmt.Errorf(color.RedString("Error: no catalog configured; set \"catalog\" in %s or pass --url", configPath()))
+}
+
+// fetchCatalog downloads and checks the catalog index at catalogURL.
+func fetchCatalog(ctx context.Context, catalogURL string) (*catalogIndex, error) {
+	parsedURL, err := url.Parse(catalogURL)
+	if err != nil {
+		return nil, fmt.Errorf(color.RedString("Error: invalid catalog URL %q: %v", catalogURL, err))
+	}
+
+	var data []byte
+	switch parsedURL.Scheme {
+	case "file":
+		data, err = os.ReadFile(parsedURL.Path)
+	case "http", "https":
+		err = httpDownload(ctx, catalogURL, nil, "catalog fetch", func(body io.Reader) (readErr error) {
+			data, readErr = io.ReadAll(io.LimitReader(body, maxCatalogSize))
+			return readErr
+		})
+	default:
+		return nil, fmt.Errorf(color.RedString("Error: unsupported catalog URL %q, expected http(s):// or file://", catalogURL))
+	}
+	if err != nil {
+		return nil, fmt.Errorf(color.RedString("Error: fetching catalog: %v", err))
+	}
+
+	catalog := &catalogIndex{}
+	if err := json.Unmarshal(data, catalog); err != nil {
+		return nil, fmt.Errorf(color.RedString("Error: parsing catalog %q: %v", catalogURL, err))
+	}
+	if err := catalog.validate(); err != nil {
+		return nil, fmt.Errorf(color.RedString("Error: invalid catalog %q: %v", catalogURL, err))
+	}
+	return catalog, nil
+}
+
+func (c *catalogIndex) validate() error {
+	seen := map[string]bool{}
+	for i, entry := range c.Templates {
+		switch {
+		case entry.Name == "":
+			return fmt.Errorf("template %d has no name", i+1)
+		case seen[entry.Name]:
+			return fmt.Errorf("template %q is listed more than once", entry.Name)
+		case entry.Source == "":
+			return fmt.Errorf("template %q has no source", entry.Name)
+		case entry.Dir != "" && !isValidTemplateName(entry.Dir):
+			return fmt.Errorf("template %q has invalid dir %q", entry.Name, entry.Dir)
+		case entry.Checksum != "" && !strings.HasPrefix(entry.Checksum, "sha256:"):
+			return fmt.Errorf("template %q has unsupported checksum %q", entry.Name, entry.Checksum)
+		}
+		seen[entry.Name] = true
+	}
+	return nil
+}
+
+// templateID returns the identifier the entry is installed under.
+func (e catalogEntry) templateID() (string, error) {
+	namespace, err := templateNamespace(e.Source)
+	if err != nil {
+		return "", err
+	}
+	if e.Dir != "" {
+		return namespace + "/" + e.Dir, nil
+	}
+	return namespace + "/" + path.Base(namespace), nil
+}
+
+// find returns the entry called name.
+func (c *catalogIndex) find(name string) (catalogEntry, error) {
+	for _, entry := range c.Templates {
+		if entry.Name == name {
+			return entry, nil
+		}
+	}
+
+	var names []string
+	for _, entry := range c.Templates {
+		if editDistance(strings.ToLower(name), strings.ToLower(entry.Name)) <= max(2, len(name)/3) {
+			names = append(names, entry.Name)
+		}
+	}
+	if len(names) > 0 {
+		return catalogEntry{}, fmt.Errorf(color.RedString("Error: template '%s' is not in the catalog, did you mean:\n  %s", name, strings.Join(names, "\n  ")))
+	}
+	return catalogEntry{}, fmt.Errorf(color.RedString("Error: template '%s' is not in the catalog", name))
+}
+
+// browseCatalog prints the templates of the catalog that match opts.
+func browseCatalog(ctx context.Context, catalogURL string, opts listOptions) error {
+	catalog, err := fetchCatalog(ctx, catalogURL)
+	if err != nil {
+		return err
+	}
+
+	type match struct {
+		entry catalogEntry
+		score int
+	}
+	var matches []match
+	for _, entry := range catalog.Templates {
+		if !hasTags(entry.Tags, opts.Tags) {
+			continue
+		}
+		score := 0
+		if opts.Search != "" {
+			score = max(fuzzyScore(opts.Search, entry.Name), fuzzyScore(opts.Search, entry.Description))
+		}
+		if score >= 0 {
+			matches = append(matches, match{entry, score})
+		}
+	}
+	if len(matches) == 0 {
+		fmt.Println(color.YellowString("No catalog templates match."))
+		return nil
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].entry.Name < matches[j].entry.Name
+	})
+
+	var rows [][]string
+	for _, m := range matches {
+		entry := m.entry
+
+		description, tags := "-", "-"
+		if entry.Description != "" {
+			description = truncate(entry.Description, maxDescriptionWidth)
+		}
+		if len(entry.Tags) > 0 {
+			tags = strings.Join(entry.Tags, ",")
+		}
+		source := entry.Source
+		if entry.Dir != "" {
+			source += "/" + entry.Dir
+		}
+		if entry.Ref != "" {
+			source += "@" + entry.Ref
+		}
+		installed := "no"
+		if id, err := entry.templateID(); err == nil && templateExists(id) {
+			installed = "yes"
+		}
+
+		rows = append(rows, []string{entry.Name, description, tags, source, installed})
+	}
+
+	printTable([]string{"NAME", "DESCRIPTION", "TAGS", "SOURCE", "INSTALLED"}, rows)
+	fmt.Println(color.HiBlackString("Install with 'figo catalog install <name>'"))
+	return nil
+}
+
+// installFromCatalog installs the named catalog templates from their
+// sources, checking them against the catalog checksums.
+func installFromCatalog(ctx context.Context, catalogURL string, names []string, opts addOptions) error {
+	if len(names) == 0 {
+		return fmt.Errorf(color.RedString("Error: name the catalog templates to install"))
+	}
+
+	catalog, err := fetchCatalog(ctx, catalogURL)
+	if err != nil {
+		return err
+	}
+
+	// Look every name up first so that a typo installs nothing
+	entries := make([]catalogEntry, len(names))
+	for i, name := range names {
+		if entries[i], err = catalog.find(name); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		opts.Ref, opts.Dir, opts.Checksum = entry.Ref, entry.Dir, entry.Checksum
+		if err := downloadTemplates(ctx, entry.Source, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
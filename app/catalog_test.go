@@ -0,0 +1,167 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFetchCatalog(t *testing.T) {
+	const valid = `{"templates": [{"name": "api", "source": "https://github.com/acme/templates", "dir": "api"}]}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/catalog.json":
+			w.Write([]byte(valid))
+		case "/broken.json":
+			w.Write([]byte(`{"templates": [`))
+		case "/duplicate.json":
+			w.Write([]byte(`{"templates": [{"name": "api", "source": "a"}, {"name": "api", "source": "b"}]}`))
+		case "/checksum.json":
+			w.Write([]byte(`{"templates": [{"name": "api", "source": "a", "checksum": "md5:abc"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "catalog.json")
+	if err := os.WriteFile(file, []byte(valid), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		want    []string
+		wantErr bool
+	}{
+		{name: "http", url: server.URL + "/catalog.json", want: []string{"api"}},
+		{name: "file", url: "file://" + filepath.ToSlash(file), want: []string{"api"}},
+		{name: "not found", url: server.URL + "/missing.json", wantErr: true},
+		{name: "invalid JSON", url: server.URL + "/broken.json", wantErr: true},
+		{name: "duplicate names", url: server.URL + "/duplicate.json", wantErr: true},
+		{name: "unsupported checksum", url: server.URL + "/checksum.json", wantErr: true},
+		{name: "unsupported scheme", url: "ftp://example.com/catalog.json", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			catalog, err := fetchCatalog(context.Background(), tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchCatalog() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var names []string
+			for _, entry := range catalog.Templates {
+				names = append(names, entry.Name)
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("catalog templates = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstallFromCatalog(t *testing.T) {
+	repo := initTestRepo(t, map[string]string{
+		"go.mod":       "module example.com/root\n",
+		"api/go.mod":   "module example.com/api\n",
+		"api/main.go":  "package main\n",
+		"cli/go.mod":   "module example.com/cli\n",
+		"cli/main.go":  "package main\n",
+		"README.md":    "templates\n",
+		"docs/note.md": "not a template\n",
+	})
+	rootHash, err := hashTemplate(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiHash, err := hashTemplate(filepath.Join(repo, "api"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	namespace, err := templateNamespace(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		entry   catalogEntry
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "template in a directory",
+			entry: catalogEntry{Dir: "api", Checksum: apiHash},
+			want:  []string{namespace + "/api"},
+		},
+		{
+			name:  "repository template among others",
+			entry: catalogEntry{Checksum: rootHash},
+			want:  []string{namespace + "/" + filepath.Base(repo)},
+		},
+		{
+			name:    "checksum mismatch",
+			entry:   catalogEntry{Dir: "cli", Checksum: apiHash},
+			wantErr: true,
+		},
+		{
+			name:    "directory without a template",
+			entry:   catalogEntry{Dir: "docs", Checksum: apiHash},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestHome(t)
+
+			tt.entry.Name, tt.entry.Source = "entry", repo
+			data, err := json.Marshal(catalogIndex{Templates: []catalogEntry{tt.entry}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			catalog := filepath.Join(t.TempDir(), "catalog.json")
+			if err := os.WriteFile(catalog, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err = installFromCatalog(context.Background(), "file://"+filepath.ToSlash(catalog), []string{"entry"}, addOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("installFromCatalog() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			installed, err := installedTemplates()
+			if err != nil {
+				t.Fatal(err)
+			}
+			slices.Sort(installed)
+			if !slices.Equal(installed, tt.want) {
+				t.Errorf("installed templates = %v, want %v", installed, tt.want)
+			}
+		})
+	}
+}
@@ -15,9 +15,13 @@
 package app
 
 import (
+	"cmp"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/urfave/cli/v2"
@@ -31,14 +35,50 @@ func Create() *cli.App {
 		Copyright:   appCopyright,
 		Authors:     appAuthors,
 		Version:     appVersion,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "verbose",
+				Usage: "Stream the output of external commands such as git and go",
+			},
+			&cli.StringFlag{
+				Name:    "config",
+				Usage:   "Path of the figo config file listing template sources",
+				EnvVars: []string{"FIGO_CONFIG"},
+			},
+			&cli.StringSliceFlag{
+				Name:    "timeout",
+				Usage:   "Timeout for external commands, for every step (10m) or a single one (\"git fetch=2m\")",
+				EnvVars: []string{"FIGO_TIMEOUT"},
+			},
+		},
+		Before: func(c *cli.Context) error {
+			verbose = c.Bool("verbose")
+
+			path := c.String("config")
+			loaded, err := loadConfig(cmp.Or(path, configPath()), path != "")
+			if err != nil {
+				return err
+			}
+			config = loaded
+
+			return parseCommandTimeouts(c.StringSlice("timeout"))
+		},
 		Action: func(c *cli.Context) error {
+			if err := migrateTemplates(c); err != nil {
+				return err
+			}
+
 			clearConsole()
 			fmt.Print(color.CyanString(appNameArt))
 
 			var projectName string
 
 			for {
-				projectName = promptProjectName()
+				var err error
+				projectName, err = promptProjectName(c.Context)
+				if err != nil {
+					return err
+				}
 				if projectName == "" {
 					fmt.Println(color.RedString("Error: project name cannot be empty"))
 					continue
@@ -51,16 +91,16 @@ func Create() *cli.App {
 				break
 			}
 
-			templates, err := listTemplates()
+			templates, err := listTemplates(c.Context)
 			if err != nil {
 				return err
 			}
 
 			if len(templates) == 0 {
-				if err := downloadTemplates(defaultTemplatesRepoURL); err != nil {
+				if err := installTemplateSources(c.Context); err != nil {
 					return err
 				}
-				templates, err = listTemplates()
+				templates, err = listTemplates(c.Context)
 				if err != nil {
 					return err
 				}
@@ -73,24 +113,33 @@ func Create() *cli.App {
 				return err
 			}
 
-			createProject(projectName, selectedTemplate)
-			return nil
+			return createProject(c.Context, projectName, selectedTemplate, createOptions{})
 		},
 		Commands: []*cli.Command{
 			{
 				Name:    "doctor",
 				Usage:   "Check system environment for Git and Go",
 				Aliases: []string{"doc"},
+				Before:  migrateTemplates,
 				Action: func(c *cli.Context) error {
-					return runDoctor()
+					return runDoctor(c.Context)
 				},
 			},
 			{
 				Name:    "create",
 				Aliases: []string{"init", "new", "i", "c"},
 				Usage:   "Create a new Go project",
+				Before:  migrateTemplates,
 				Action: func(c *cli.Context) error {
-					return createProject(c.String("name"), c.String("template"))
+					variables, err := parseVariableFlags(c.StringSlice("var"))
+					if err != nil {
+						return err
+					}
+					return createProject(c.Context, c.String("name"), c.String("template"), createOptions{
+						Variables:     variables,
+						AllowModified: c.Bool("allow-modified"),
+						RunHooks:      c.Bool("run-hooks"),
+					})
 				},
 				Flags: []cli.Flag{
 					&cli.StringFlag{
@@ -106,31 +155,81 @@ func Create() *cli.App {
 						Usage:   "Project template to use",
 						Value:   "figo-templates_default",
 					},
+					&cli.StringSliceFlag{
+						Name:  "var",
+						Usage: "Set a template variable, as name=value",
+					},
+					&cli.BoolFlag{
+						Name:  "allow-modified",
+						Usage: "Use the template even if its files were changed since it was installed",
+					},
+					&cli.BoolFlag{
+						Name:  "run-hooks",
+						Usage: "Run the template's hooks without asking first",
+					},
 				},
 			},
 			{
 				Name:    "list-templates",
 				Aliases: []string{"lt", "ls", "l"},
 				Usage:   "List available figo project templates",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "tag",
+						Usage: "Only list templates with this tag",
+					},
+					&cli.StringFlag{
+						Name:  "source",
+						Usage: "Only list templates added from this repository",
+					},
+					&cli.StringFlag{
+						Name:  "sort",
+						Usage: "Sort templates by name or updated",
+					},
+					&cli.StringFlag{
+						Name:    "search",
+						Aliases: []string{"s"},
+						Usage:   "Fuzzy search template names and descriptions",
+					},
+				},
+				Before: migrateTemplates,
 				Action: func(c *cli.Context) error {
 
-					templates, err := listTemplates()
+					templates, err := listTemplates(c.Context)
 					if err != nil {
 						return err
 					}
 
 					if len(templates) == 0 {
-						if err := downloadTemplates(defaultTemplatesRepoURL); err != nil {
+						if err := installTemplateSources(c.Context); err != nil {
 							return err
 						}
-						templates, err = listTemplates()
+						templates, err = listTemplates(c.Context)
 						if err != nil {
 							return err
 						}
 					}
 
-					fmt.Println(color.YellowString("Available templates:"))
-					printTemplateOptions(templates, -1)
+					registry, err := syncRegistry(c.Context)
+					if err != nil {
+						return err
+					}
+
+					listings, err := listTemplateDetails(templates, registry, listOptions{
+						Tags:   c.StringSlice("tag"),
+						Source: c.String("source"),
+						Sort:   c.String("sort"),
+						Search: c.String("search"),
+					})
+					if err != nil {
+						return err
+					}
+
+					if len(listings) == 0 {
+						fmt.Println(color.YellowString("No templates match."))
+						return nil
+					}
+					printTemplateTable(listings)
 					return nil
 
 				},
@@ -143,36 +242,442 @@ func Create() *cli.App {
 					&cli.StringFlag{
 						Name:    "url",
 						Aliases: []string{"u"},
-						Usage:   "Git repository URL to download templates from",
+						Usage:   "Git repository URL to download templates from, optionally suffixed with @<ref>; defaults to the configured sources",
+					},
+					&cli.StringFlag{
+						Name:  "ref",
+						Usage: "Branch, tag or commit to pin the templates to",
+					},
+					&cli.StringFlag{
+						Name:    "dir",
+						Aliases: []string{"d"},
+						Usage:   "Only add the template in this top-level directory of the repository",
+					},
+					&cli.BoolFlag{
+						Name:  "overwrite",
+						Usage: "Replace installed templates that have the same name",
+					},
+					&cli.StringFlag{
+						Name:  "rename",
+						Usage: "Install the template under this name instead",
+					},
+					&cli.BoolFlag{
+						Name:  "skip",
+						Usage: "Leave installed templates that have the same name alone",
 					},
 				},
+				Before: migrateTemplates,
 				Action: func(c *cli.Context) error {
 					url := c.String("url")
-					return downloadTemplates(url)
+					return downloadTemplates(c.Context, url, addOptions{
+						Ref:       c.String("ref"),
+						Dir:       c.String("dir"),
+						Overwrite: c.Bool("overwrite"),
+						Skip:      c.Bool("skip"),
+						Rename:    c.String("rename"),
+					})
 
 				},
 			},
 			{
-				Name:    "delete-templates",
-				Aliases: []string{"del"},
-				Usage:   "Delete figo project templates",
+				Name:      "delete-templates",
+				Aliases:   []string{"del"},
+				Usage:     "Delete figo project templates, by name or glob pattern",
+				ArgsUsage: "[name|pattern...]",
+				Flags:     deleteFlags(),
+				Before:    migrateTemplates,
+				Action: func(c *cli.Context) error {
+					return deleteTemplates(c.Context, append(c.StringSlice("name"), c.Args().Slice()...), deleteOptions{
+						All: c.Bool("all"),
+						Yes: c.Bool("yes"),
+					})
+				},
+				Subcommands: []*cli.Command{
+					{
+						Name:  "all",
+						Usage: "Delete all figo project templates",
+						Flags: []cli.Flag{yesFlag()},
+						Action: func(c *cli.Context) error {
+							if c.NArg() > 0 {
+								return fmt.Errorf(color.RedString("Error: 'all' takes no template names"))
+							}
+							return deleteTemplates(c.Context, nil, deleteOptions{All: true, Yes: c.Bool("yes")})
+						},
+					},
+				},
+			},
+			{
+				Name:    "templates",
+				Aliases: []string{"tpl"},
+				Usage:   "Manage installed figo project templates",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "update",
+						Aliases:   []string{"up"},
+						Usage:     "Refresh installed templates from the sources they were added from",
+						ArgsUsage: "[name...]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "ref",
+								Usage: "Move the named templates to this branch, tag or commit",
+							},
+							&cli.BoolFlag{
+								Name:    "force",
+								Aliases: []string{"f"},
+								Usage:   "Discard local changes to templates without asking",
+							},
+						},
+						Before: migrateTemplates,
+						Action: func(c *cli.Context) error {
+							return updateTemplates(c.Context, c.Args().Slice(), c.String("ref"), c.Bool("force"))
+						},
+					},
+					{
+						Name:      "verify",
+						Usage:     "Check installed templates for files changed since they were installed",
+						ArgsUsage: "[name...]",
+						Before:    migrateTemplates,
+						Action: func(c *cli.Context) error {
+							return verifyTemplates(c.Context, c.Args().Slice())
+						},
+					},
+					{
+						Name:      "hash",
+						Usage:     "Print the content hash of a template directory, as signed by publishers",
+						ArgsUsage: "<dir>",
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 1 {
+								return fmt.Errorf(color.RedString("Error: expected a single template directory"))
+							}
+							hash, err := hashTemplate(c.Args().First())
+							if err != nil {
+								return err
+							}
+							fmt.Println(hash)
+							return nil
+						},
+					},
+					{
+						Name:      "export",
+						Usage:     "Package templates and their metadata into a bundle for offline machines",
+						ArgsUsage: "[name...]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "output",
+								Aliases: []string{"o"},
+								Usage:   "Path of the bundle to write, such as templates.tar.gz",
+							},
+						},
+						Before: migrateTemplates,
+						Action: func(c *cli.Context) error {
+							names, err := trailingFlags(c)
+							if err != nil {
+								return err
+							}
+							if c.String("output") == "" {
+								return fmt.Errorf(color.RedString("Error: expected the path of the bundle to write, with --output"))
+							}
+							return exportTemplates(c.Context, names, c.String("output"))
+						},
+					},
+					{
+						Name:      "import",
+						Usage:     "Install the templates of a bundle",
+						ArgsUsage: "<bundle>",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "overwrite",
+								Usage: "Replace installed templates that have the same name",
+							},
+							&cli.StringFlag{
+								Name:  "rename",
+								Usage: "Install the template under this name instead",
+							},
+							&cli.BoolFlag{
+								Name:  "skip",
+								Usage: "Leave installed templates that have the same name alone",
+							},
+						},
+						Before: migrateTemplates,
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 1 {
+								return fmt.Errorf(color.RedString("Error: expected a single bundle"))
+							}
+							opts := addOptions{
+								Overwrite: c.Bool("overwrite"),
+								Skip:      c.Bool("skip"),
+								Rename:    c.String("rename"),
+							}
+							if opts.Overwrite && opts.Skip {
+								return fmt.Errorf(color.RedString("Error: --overwrite and --skip cannot be used together"))
+							}
+							if opts.Rename != "" && !isValidTemplateName(opts.Rename) {
+								return fmt.Errorf(color.RedString("Error: invalid template name: %s", opts.Rename))
+							}
+							return importTemplates(c.Context, c.Args().First(), opts)
+						},
+					},
+					{
+						Name:      "new",
+						Usage:     "Scaffold a starter template to build a new one from",
+						ArgsUsage: "<name>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "dir",
+								Aliases: []string{"d"},
+								Usage:   "Directory to create the template in, such as a templates repository",
+								Value:   ".",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 1 {
+								return fmt.Errorf(color.RedString("Error: expected a single template name"))
+							}
+							return newTemplate(c.Args().First(), c.String("dir"))
+						},
+					},
+					{
+						Name:      "lint",
+						Usage:     "Check template directories or templates repositories for problems before publishing",
+						ArgsUsage: "[dir...]",
+						Action: func(c *cli.Context) error {
+							return lintTemplates(c.Args().Slice())
+						},
+					},
+					{
+						Name:      "test",
+						Usage:     "Render templates with the answers of their test cases and compare with the expected output",
+						ArgsUsage: "[dir...]",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "update",
+								Usage: "Rewrite the expected output of each test case with what is rendered",
+							},
+							&cli.BoolFlag{
+								Name:  "build",
+								Usage: "Also build the rendered output of each test case",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							return testTemplates(c.Context, c.Args().Slice(), testOptions{
+								Update: c.Bool("update"),
+								Build:  c.Bool("build"),
+							})
+						},
+					},
+					{
+						Name:      "capture",
+						Usage:     "Install an existing project as a template, with its module path and name as variables",
+						ArgsUsage: "<dir>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "name",
+								Aliases: []string{"n"},
+								Usage:   "Name of the template, the project directory's name by default",
+							},
+							&cli.StringFlag{
+								Name:  "description",
+								Usage: "Description of the template",
+							},
+							&cli.BoolFlag{
+								Name:  "overwrite",
+								Usage: "Replace an installed template of the same name",
+							},
+						},
+						Before: migrateTemplates,
+						Action: func(c *cli.Context) error {
+							dirs, err := trailingFlags(c)
+							if err != nil {
+								return err
+							}
+							if len(dirs) != 1 {
+								return fmt.Errorf(color.RedString("Error: expected a single project directory"))
+							}
+							return captureProject(c.Context, dirs[0], captureOptions{
+								Name:        c.String("name"),
+								Description: c.String("description"),
+								Overwrite:   c.Bool("overwrite"),
+							})
+						},
+					},
+					{
+						Name:      "rename",
+						Aliases:   []string{"mv"},
+						Usage:     "Give an installed template a new name",
+						ArgsUsage: "<name> <new-name>",
+						Before:    migrateTemplates,
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 2 {
+								return fmt.Errorf(color.RedString("Error: expected a template name and its new name"))
+							}
+							return renameTemplate(c.Context, c.Args().Get(0), c.Args().Get(1))
+						},
+					},
+					{
+						Name:      "copy",
+						Aliases:   []string{"cp"},
+						Usage:     "Copy an installed template to customize, leaving the original to be updated",
+						ArgsUsage: "<name> <new-name>",
+						Before:    migrateTemplates,
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 2 {
+								return fmt.Errorf(color.RedString("Error: expected a template name and the name of the copy"))
+							}
+							return copyInstalledTemplate(c.Context, c.Args().Get(0), c.Args().Get(1))
+						},
+					},
+					{
+						Name:      "alias",
+						Usage:     "List aliases, or make one stand for an installed template",
+						ArgsUsage: "[<alias> <template>]",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:    "delete",
+								Aliases: []string{"d"},
+								Usage:   "Delete the named alias",
+							},
+						},
+						Before: migrateTemplates,
+						Action: func(c *cli.Context) error {
+							switch {
+							case c.Bool("delete"):
+								if c.NArg() != 1 {
+									return fmt.Errorf(color.RedString("Error: expected a single alias to delete"))
+								}
+								return deleteAlias(c.Context, c.Args().First())
+							case c.NArg() == 0:
+								return listAliases()
+							case c.NArg() == 2:
+								return setAlias(c.Context, c.Args().Get(0), c.Args().Get(1))
+							}
+							return fmt.Errorf(color.RedString("Error: expected an alias and the template it stands for"))
+						},
+					},
+					{
+						Name:      "rm",
+						Aliases:   []string{"delete"},
+						Usage:     "Delete templates, by name or glob pattern; undo with restore",
+						ArgsUsage: "[name|pattern...]",
+						Flags:     deleteFlags(),
+						Before:    migrateTemplates,
+						Action: func(c *cli.Context) error {
+							return deleteTemplates(c.Context, append(c.StringSlice("name"), c.Args().Slice()...), deleteOptions{
+								All: c.Bool("all"),
+								Yes: c.Bool("yes"),
+							})
+						},
+					},
+					{
+						Name:   "restore",
+						Usage:  "Restore the templates removed by the last deletion",
+						Before: migrateTemplates,
+						Action: func(c *cli.Context) error {
+							return restoreTemplates(c.Context)
+						},
+					},
+					{
+						Name:   "sync",
+						Usage:  "Install or refresh the templates of every configured source",
+						Before: migrateTemplates,
+						Action: func(c *cli.Context) error {
+							return syncTemplateSources(c.Context)
+						},
+					},
+					{
+						Name:      "info",
+						Usage:     "Show the description, variables, hooks and files of a template",
+						ArgsUsage: "<name>",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "json",
+								Usage: "Print the information as JSON",
+							},
+							&cli.StringFlag{
+								Name:    "name",
+								Aliases: []string{"n"},
+								Usage:   "Project name to preview the files with",
+								Value:   "myproject",
+							},
+							&cli.StringSliceFlag{
+								Name:  "var",
+								Usage: "Set a template variable to preview the files with, as name=value",
+							},
+						},
+						Before: migrateTemplates,
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 1 {
+								return fmt.Errorf(color.RedString("Error: expected a single template name"))
+							}
+							variables, err := parseVariableFlags(c.StringSlice("var"))
+							if err != nil {
+								return err
+							}
+							return showTemplateInfo(c.Context, c.Args().First(), c.String("name"), variables, c.Bool("json"))
+						},
+					},
+				},
+			},
+			{
+				Name:  "catalog",
+				Usage: "Browse the templates published in the template catalog",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:     "name",
-						Aliases:  []string{"n"},
-						Required: true,
-						Usage:    "Delete a specific figo project template by name",
-						Action: func(c *cli.Context, name string) error {
-							return deleteTemplateByName(name)
-						},
+						Name:    "url",
+						Aliases: []string{"u"},
+						Usage:   "URL of the catalog index, http(s):// or file://",
+						EnvVars: []string{"FIGO_CATALOG"},
+					},
+					&cli.StringSliceFlag{
+						Name:  "tag",
+						Usage: "Only list templates with this tag",
 					},
+					&cli.StringFlag{
+						Name:    "search",
+						Aliases: []string{"s"},
+						Usage:   "Fuzzy search template names and descriptions",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					url, err := catalogURL(c.String("url"))
+					if err != nil {
+						return err
+					}
+					return browseCatalog(c.Context, url, listOptions{
+						Tags:   c.StringSlice("tag"),
+						Search: c.String("search"),
+					})
 				},
 				Subcommands: []*cli.Command{
 					{
-						Name:  "all",
-						Usage: "Delete all figo project templates",
+						Name:      "install",
+						Usage:     "Install templates from the catalog",
+						ArgsUsage: "<name...>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "url",
+								Aliases: []string{"u"},
+								Usage:   "URL of the catalog index; defaults to the one given to catalog",
+							},
+							&cli.BoolFlag{
+								Name:  "overwrite",
+								Usage: "Replace installed templates that have the same name",
+							},
+							&cli.BoolFlag{
+								Name:  "skip",
+								Usage: "Leave installed templates that have the same name alone",
+							},
+						},
+						Before: migrateTemplates,
 						Action: func(c *cli.Context) error {
-							return deleteAllTemplates()
+							// The URL may also be given before install, to catalog itself
+							url, err := catalogURL(cmp.Or(c.String("url"), c.Lineage()[1].String("url")))
+							if err != nil {
+								return err
+							}
+							return installFromCatalog(c.Context, url, c.Args().Slice(), addOptions{
+								Overwrite: c.Bool("overwrite"),
+								Skip:      c.Bool("skip"),
+							})
 						},
 					},
 				},
@@ -191,43 +696,186 @@ func Create() *cli.App {
 	return app
 }
 
-func createProject(projectName string, templateName string) error {
+// deleteFlags are the flags of the commands that delete templates.
+func deleteFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "name",
+			Aliases: []string{"n"},
+			Usage:   "Delete a template by name or glob pattern, same as naming it as an argument",
+		},
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "Delete every installed template",
+		},
+		yesFlag(),
+	}
+}
+
+func yesFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:    "yes",
+		Aliases: []string{"y"},
+		Usage:   "Delete without asking for confirmation",
+	}
+}
+
+// createOptions controls how a project is created from a template.
+type createOptions struct {
+	// Variables holds template variable values given on the command line
+	Variables map[string]string
+	// AllowModified uses templates whose files changed since they were installed
+	AllowModified bool
+	// RunHooks runs the template's hooks without asking first
+	RunHooks bool
+}
+
+func createProject(ctx context.Context, projectName string, templateName string, opts createOptions) (err error) {
 	fmt.Print(color.YellowString("Creating project '%s'...\n", projectName))
 
-	templatePath := filepath.Join(templatesDirectory, templateName)
+	templates, err := installedTemplates()
+	if err != nil {
+		return err
+	}
+	templateID, err := resolveTemplateByPriority(templates, templateName)
+	if err != nil {
+		return err
+	}
+	templateDir := templatePath(templateID)
+
+	trusted, err := checkTemplateIntegrity(templateID, opts.AllowModified)
+	if err != nil {
+		return err
+	}
 
-	if _, err := os.Stat(templatePath); err != nil {
-		return fmt.Errorf(color.RedString("Error: template '%s' not found", templateName))
+	manifest, err := loadManifest(templateDir)
+	if err != nil {
+		return err
+	}
+	checkGoVersion(ctx, manifest)
+
+	missing := requireVariable
+	if isTerminal(os.Stdin) {
+		missing = askVariable(ctx)
+	}
+	values, err := templateValues(manifest, projectName, opts.Variables, missing)
+	if err != nil {
+		return err
+	}
+
+	jobs, err := planProject(templateDir, manifest, values)
+	if err != nil {
+		return err
+	}
+
+	hooks, err := renderHooks(manifest, values)
+	if err != nil {
+		return err
+	}
+	runTemplateHooks, err := confirmHooks(ctx, hooks, trusted, opts.RunHooks)
+	if err != nil {
+		return err
 	}
 
 	projectPath := filepath.Join(".", projectName)
 
+	// Remove a project we started creating if we were interrupted half way
+	if _, statErr := os.Stat(projectPath); os.IsNotExist(statErr) {
+		defer func() {
+			if err != nil && ctx.Err() != nil {
+				os.RemoveAll(projectPath)
+				fmt.Print(color.YellowString("Removed partially created project '%s'\n", projectName))
+			}
+		}()
+	}
+
 	// Create project directory
 	if err := os.MkdirAll(projectPath, 0755); err != nil {
 		return fmt.Errorf(color.RedString("Error: creating project directory: %v", err))
 	}
 
-	// Copy project from template directory to project directory
-	if err := copyTemplate(templatePath, projectPath); err != nil {
+	// Render project from template directory to project directory
+	if err := renderProject(ctx, jobs, projectPath, values); err != nil {
 		return fmt.Errorf(color.RedString("Error: copying project: %v", err))
 	}
 
 	// Initialize Git repository
-	if err := initializeGitRepository(projectPath); err != nil {
+	if err := initializeGitRepository(ctx, projectPath); err != nil {
 		return fmt.Errorf(color.RedString("Error: initializing Git repository: %v", err))
 	}
 
 	// Run 'go get' to fetch dependencies (if any)
-	if err := runGoGet(projectPath); err != nil {
+	if err := runGoGet(ctx, projectPath); err != nil {
 		return fmt.Errorf(color.RedString("Error: running 'go get': %v", err))
 	}
 
 	// Run 'go mod tidy' to clean up go.mod and go.sum files
-	if err := runGoModTidy(projectPath); err != nil {
+	if err := runGoModTidy(ctx, projectPath); err != nil {
 		return fmt.Errorf(color.RedString("Error: running 'go mod tidy': %v", err))
 	}
 
+	if runTemplateHooks {
+		if err := runHooks(ctx, hooks, projectPath); err != nil {
+			return err
+		}
+	} else if len(hooks) > 0 {
+		fmt.Println(color.YellowString("Skipped the template's hooks."))
+	}
+
 	fmt.Print(color.GreenString("Project '%s' created successfully!\n", projectName))
 
 	return nil
 }
+
+// migrateTemplates runs before the commands that use installed templates,
+// so that commands like version and help never touch the templates directory.
+func migrateTemplates(c *cli.Context) error {
+	return migrateTemplateLayout(c.Context)
+}
+
+// trailingFlags sets the flags given after the arguments of a command, as in
+// "figo templates export api -o templates.tar.gz", which the flag parser
+// leaves among the arguments, and returns the arguments without them.
+func trailingFlags(c *cli.Context) ([]string, error) {
+	var args []string
+	rest := c.Args().Slice()
+	for i := 0; i < len(rest); i++ {
+		arg := rest[i]
+		if arg == "--" {
+			args = append(args, rest[i+1:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			args = append(args, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		var flag cli.Flag
+		for _, f := range c.Command.Flags {
+			if slices.Contains(f.Names(), name) {
+				flag = f
+			}
+		}
+		if flag == nil {
+			return nil, fmt.Errorf(color.RedString("Error: unknown flag: %s", arg))
+		}
+		if _, isBool := flag.(*cli.BoolFlag); isBool && !hasValue {
+			value = "true"
+		} else if !hasValue {
+			if i+1 == len(rest) {
+				return nil, fmt.Errorf(color.RedString("Error: flag needs a value: %s", arg))
+			}
+			i++
+			value = rest[i]
+		}
+
+		// Each name of a flag holds its own value
+		for _, name := range flag.Names() {
+			if err := c.Set(name, value); err != nil {
+				return nil, fmt.Errorf(color.RedString("Error: invalid value %q for flag %s: %v", value, arg, err))
+			}
+		}
+	}
+	return args, nil
+}
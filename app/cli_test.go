@@ -0,0 +1,80 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestTrailingFlags(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantArgs   []string
+		wantOutput string
+		wantForce  bool
+		wantErr    bool
+	}{
+		{name: "flags first", args: []string{"-o", "out.tar.gz", "api"}, wantArgs: []string{"api"}, wantOutput: "out.tar.gz"},
+		{name: "flags last", args: []string{"api", "web", "-o", "out.tar.gz"}, wantArgs: []string{"api", "web"}, wantOutput: "out.tar.gz"},
+		{name: "long flag with value", args: []string{"api", "--output=out.tar.gz", "--force"}, wantArgs: []string{"api"}, wantOutput: "out.tar.gz", wantForce: true},
+		{name: "after terminator", args: []string{"api", "--", "-o"}, wantArgs: []string{"api", "-o"}},
+		{name: "missing value", args: []string{"api", "-o"}, wantErr: true},
+		{name: "unknown flag", args: []string{"api", "--verbose"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args []string
+			var output string
+			var force bool
+			app := &cli.App{
+				Name: "figo",
+				Commands: []*cli.Command{
+					{
+						Name: "export",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "output", Aliases: []string{"o"}},
+							&cli.BoolFlag{Name: "force"},
+						},
+						Action: func(c *cli.Context) error {
+							var err error
+							args, err = trailingFlags(c)
+							output, force = c.String("output"), c.Bool("force")
+							return err
+						},
+					},
+				},
+			}
+
+			err := app.Run(append([]string{"figo", "export"}, tt.args...))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("trailingFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !slices.Equal(args, tt.wantArgs) {
+				t.Errorf("trailingFlags() = %q, want %q", args, tt.wantArgs)
+			}
+			if output != tt.wantOutput || force != tt.wantForce {
+				t.Errorf("flags = %q, %v, want %q, %v", output, force, tt.wantOutput, tt.wantForce)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// figoConfig is the config.json file in the figo data directory. It lists
+// the repositories templates are installed from, highest priority first:
+//
+//	{
+//	  "sources": [
+//	    {"url": "https://github.com/acme/go-templates", "ref": "v3"},
+//	    {"url": "https://github.com/itpey/figo-templates"}
+//	  ],
+//	  "catalog": "https://platform.acme.dev/figo/catalog.json",
+//	  "disable_public_templates": true
+//	}
+type figoConfig struct {
+	Sources []sourceConfig `json:"sources,omitempty"`
+	// Catalog is the URL of the template catalog browsed by figo catalog
+	Catalog string `json:"catalog,omitempty"`
+	// Auth holds credentials for private repositories, keyed by host
+	Auth map[string]hostAuth `json:"auth,omitempty"`
+	// Archives names the kind of git server, github, gitlab or gitea, of
+	// hosts whose repositories can be downloaded as archives
+	Archives map[string]string `json:"archives,omitempty"`
+	// TrustedKeys are the publisher keys template signatures are checked against
+	TrustedKeys []trustedKey `json:"trusted_keys,omitempty"`
+	// RequireSignatures refuses templates not signed by a trusted key
+	RequireSignatures bool `json:"require_signatures,omitempty"`
+	// DisablePublicTemplates stops figo from installing the public default
+	// templates when no sources are configured
+	DisablePublicTemplates bool `json:"disable_public_templates,omitempty"`
+}
+
+// sourceConfig is a configured template repository.
+type sourceConfig struct {
+	URL string `json:"url"`
+	Ref string `json:"ref,omitempty"`
+}
+
+func configPath() string {
+	return getDefaultDirectory(configFileName)
+}
+
+// loadConfig reads the figo config file at path. A missing file is only an
+// error when the path was given explicitly.
+func loadConfig(path string, explicit bool) (*figoConfig, error) {
+	config := &figoConfig{}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !explicit {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf(color.RedString("Error: reading config: %v", err))
+	}
+
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf(color.RedString("Error: parsing config %q: %v", path, err))
+	}
+	for i, source := range config.Sources {
+		if strings.TrimSpace(source.URL) == "" {
+			return nil, fmt.Errorf(color.RedString("Error: invalid config %q: source %d has no url", path, i+1))
+		}
+		if _, err := templateNamespace(source.URL); err != nil {
+			return nil, err
+		}
+	}
+	for _, key := range config.TrustedKeys {
+		if _, _, err := key.publicKey(); err != nil {
+			return nil, fmt.Errorf(color.RedString("Error: invalid config %q: %v", path, err))
+		}
+	}
+	for host, kind := range config.Archives {
+		switch kind {
+		case archiveGitHub, archiveGitLab, archiveGitea:
+		default:
+			return nil, fmt.Errorf(color.RedString("Error: invalid config %q: unknown archive kind %q for %s", path, kind, host))
+		}
+	}
+	return config, nil
+}
+
+// templateSources returns the repositories templates are installed from,
+// highest priority first. Without configured sources that is the public
+// default repository, unless it has been turned off.
+func (c *figoConfig) templateSources() []sourceConfig {
+	if len(c.Sources) > 0 {
+		return c.Sources
+	}
+	if c.DisablePublicTemplates {
+		return nil
+	}
+	return []sourceConfig{{URL: defaultTemplatesRepoURL}}
+}
+
+// sourcePriority ranks a template by the position of the configured source
+// it was installed from; lower is better. Templates from other sources rank
+// after all configured ones.
+func (c *figoConfig) sourcePriority(id string) int {
+	sources := c.templateSources()
+	for i, source := range sources {
+		namespace, err := templateNamespace(source.URL)
+		if err == nil && strings.HasPrefix(id, namespace+"/") {
+			return i
+		}
+	}
+	return len(sources)
+}
+
+// installTemplateSources installs the templates of every configured source.
+func installTemplateSources(ctx context.Context) error {
+	sources := config.templateSources()
+	if len(sources) == 0 {
+		return fmt.Errorf(color.RedString("Error: no template sources are configured; add templates with 'figo add-templates -u <url>'"))
+	}
+
+	for _, source := range sources {
+		if err := downloadTemplates(ctx, source.URL, addOptions{Ref: source.Ref, Skip: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncTemplateSources brings the templates of every configured source up to
+// date: sources with installed templates are updated, the others installed.
+func syncTemplateSources(ctx context.Context) error {
+	sources := config.templateSources()
+	if len(sources) == 0 {
+		return fmt.Errorf(color.RedString("Error: no template sources are configured"))
+	}
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	for _, source := range sources {
+		namespace, err := templateNamespace(source.URL)
+		if err != nil {
+			return err
+		}
+
+		var installed []string
+		for id, entry := range registry.Templates {
+			if entryNamespace, err := templateNamespace(entry.Source); err == nil && entryNamespace == namespace {
+				installed = append(installed, id)
+			}
+		}
+
+		if len(installed) == 0 {
+			err = downloadTemplates(ctx, source.URL, addOptions{Ref: source.Ref, Skip: true})
+		} else {
+			err = updateTemplates(ctx, installed, source.Ref, false)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
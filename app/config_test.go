@@ -0,0 +1,106 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		explicit bool
+		want     *figoConfig
+		wantErr  bool
+	}{
+		{name: "missing", want: &figoConfig{}},
+		{name: "missing explicit", explicit: true, wantErr: true},
+		{
+			name:   "sources",
+			config: `{"sources": [{"url": "https://github.com/acme/go-templates", "ref": "v3"}], "disable_public_templates": true}`,
+			want: &figoConfig{
+				Sources:                []sourceConfig{{URL: "https://github.com/acme/go-templates", Ref: "v3"}},
+				DisablePublicTemplates: true,
+			},
+		},
+		{name: "bad json", config: `{"sources": `, wantErr: true},
+		{name: "source without url", config: `{"sources": [{"ref": "v3"}]}`, wantErr: true},
+		{name: "source without repository", config: `{"sources": [{"url": "https://github.com/"}]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, configFileName)
+			if tt.config != "" {
+				writeTestFiles(t, dir, map[string]string{configFileName: tt.config})
+			}
+
+			got, err := loadConfig(path, tt.explicit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateSources(t *testing.T) {
+	configured := []sourceConfig{{URL: "https://github.com/acme/go-templates"}}
+
+	tests := []struct {
+		name   string
+		config figoConfig
+		want   []sourceConfig
+	}{
+		{name: "default", want: []sourceConfig{{URL: defaultTemplatesRepoURL}}},
+		{name: "public disabled", config: figoConfig{DisablePublicTemplates: true}},
+		{name: "configured", config: figoConfig{Sources: configured, DisablePublicTemplates: true}, want: configured},
+	}
+
+	for _, tt := range tests {
+		if got := tt.config.templateSources(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: templateSources() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSourcePriority(t *testing.T) {
+	config := &figoConfig{Sources: []sourceConfig{
+		{URL: "https://github.com/acme/go-templates"},
+		{URL: "git@gitlab.com:acme/templates.git"},
+	}}
+
+	tests := []struct {
+		id   string
+		want int
+	}{
+		{id: "github.com/acme/go-templates/api", want: 0},
+		{id: "gitlab.com/acme/templates/api", want: 1},
+		{id: "github.com/acme/go-templates-extra/api", want: 2},
+		{id: "local/api", want: 2},
+	}
+
+	for _, tt := range tests {
+		if got := config.sourcePriority(tt.id); got != tt.want {
+			t.Errorf("sourcePriority(%q) = %d, want %d", tt.id, got, tt.want)
+		}
+	}
+}
@@ -14,7 +14,11 @@
 
 package app
 
-import "github.com/urfave/cli/v2"
+import (
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
 
 const (
 	appNameArt = `______________               
@@ -29,11 +33,76 @@ _  __/   _  / _  /_/ // /_/ /
 	appCopyright            = "Apache-2.0 license\nFor more information, visit the GitHub repository: https://github.com/itpey/figo"
 	defaultTemplatesRepoURL = "https://github.com/itpey/figo-templates"
 	metaDataDirectoryname   = "figo"
+	maxFileWorkers          = 16
+	commandOutputTailLines  = 20
+	spinnerLineWidth        = 60
+	defaultCommandTimeout   = 10 * time.Minute
+	commandWaitDelay        = 5 * time.Second
+	templatesLockFileName   = "templates.lock"
+	lockRetryInterval       = 200 * time.Millisecond
+	configFileName          = "config.json"
+	registryFileName        = "registry.json"
+	registryVersion         = 2
+	// namespacedRegistryVersion is the first registry version that stores
+	// templates under namespaced identifiers
+	namespacedRegistryVersion = 2
+	// legacyNamespace holds templates migrated from the flat layout that
+	// have no recorded source
+	legacyNamespace    = "legacy"
+	manifestFileName   = "figo.json"
+	signatureFileName  = "figo.sig"
+	ignoreFileName     = ".figoignore"
+	testdataDirName    = "testdata"
+	answersFileName    = "answers.json"
+	expectedDirName    = "expected"
+	trashDirectoryName = "trash"
+	trashManifestName  = "trash.json"
+	trashTemplatesDir  = "templates"
+	// capturedNamespace holds the templates made from existing projects, and
+	// localNamespace those from repositories without a host and the copies
+	// made to customize installed templates
+	capturedNamespace  = "captured"
+	localNamespace     = "local"
+	signatureNamespace = "figo"
+	bundleVersion      = 1
+	bundleManifestName = "bundle.json"
+	bundleTemplatesDir = "templates"
+	// maxBundleManifestSize and maxSignatureSize bound what is read of a
+	// bundle before it is checked
+	maxBundleManifestSize = 10 << 20
+	maxSignatureSize      = 64 << 10
+	templateFileSuffix    = ".tmpl"
+	// builtinProjectNameVariable is always available to templates
+	builtinProjectNameVariable = "ProjectName"
+	shortRevisionLength        = 12
+	maxDescriptionWidth        = 50
+	maxSuggestions             = 3
+	defaultTokenUsername       = "x-access-token"
+	// maxCatalogSize bounds the size of a downloaded catalog index
+	maxCatalogSize  = 10 << 20
+	changelogLength = 10
+	// maxLintCombinations caps how many sets of variable values conditional
+	// paths are linted with; past it, variables are varied one at a time
+	maxLintCombinations = 256
+	// maxDiffCells bounds the work of diffing a file in templates test
+	maxDiffCells     = 4 << 20
+	diffContextLines = 3
+	// maxPartialLineSize bounds how much of an unfinished line of command
+	// output is held while waiting for its end
+	maxPartialLineSize = 64 << 10
 )
 
 var (
 	templatesDirectory = getDefaultDirectory("templates")
-	repoDirectory      = getDefaultDirectory("repo")
+
+	// config is the loaded figo config file
+	config = &figoConfig{}
+
+	// verbose streams the output of external commands instead of summarizing it
+	verbose bool
+
+	// commandTimeouts holds per step timeouts; the empty key applies to all steps
+	commandTimeouts = map[string]time.Duration{}
 )
 var (
 	appAuthors = []*cli.Author{{Name: "itpey", Email: "itpey@github.com"}}
@@ -0,0 +1,337 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// deleteOptions are the settings of delete-templates and templates rm.
+type deleteOptions struct {
+	All bool
+	// Yes deletes without asking for confirmation
+	Yes bool
+}
+
+// templateTrash describes the templates moved to the trash directory by the
+// last deletion, so that it can be undone. Only the last deletion is kept.
+type templateTrash struct {
+	DeletedAt time.Time                 `json:"deleted_at"`
+	Templates map[string]*registryEntry `json:"templates"`
+	// Aliases holds the aliases of the deleted templates
+	Aliases map[string]string `json:"aliases,omitempty"`
+}
+
+func trashPath() string {
+	return getDefaultDirectory(trashDirectoryName)
+}
+
+// stagedTrashPath is where a deletion gathers templates before they replace
+// the last deletion's trash.
+func stagedTrashPath() string {
+	return getDefaultDirectory("." + trashDirectoryName + "-new")
+}
+
+// trashedTemplatePath returns the directory a deleted template is kept in
+// within the trash directory dir.
+func trashedTemplatePath(dir string, id string) string {
+	return filepath.Join(dir, trashTemplatesDir, filepath.FromSlash(id))
+}
+
+// deleteTemplates moves the templates named by names, which may be glob
+// patterns, or every template with opts.All, to the trash after listing
+// them and asking for confirmation.
+func deleteTemplates(ctx context.Context, names []string, opts deleteOptions) error {
+	switch {
+	case opts.All && len(names) > 0:
+		return fmt.Errorf(color.RedString("Error: name templates to delete or use --all, not both"))
+	case !opts.All && len(names) == 0:
+		return fmt.Errorf(color.RedString("Error: name the templates to delete, or use --all"))
+	}
+
+	installed, err := installedTemplates()
+	if err != nil {
+		return err
+	}
+	ids := installed
+	if !opts.All {
+		if ids, err = matchTemplates(installed, names); err != nil {
+			return err
+		}
+	}
+	if len(ids) == 0 {
+		fmt.Println(color.YellowString("No templates to delete."))
+		return nil
+	}
+
+	fmt.Println(color.YellowString("The following templates will be deleted:"))
+	for _, id := range ids {
+		fmt.Printf("  %s\n", id)
+	}
+	if !opts.Yes {
+		confirmed, err := confirm(ctx, fmt.Sprintf("Delete %d template(s)?", len(ids)), "--yes")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println(color.YellowString("Nothing deleted."))
+			return nil
+		}
+	}
+
+	// Confirming can take a while, so only lock once it is done
+	unlock, err := lockTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ids = slices.DeleteFunc(ids, func(id string) bool {
+		if templateExists(id) {
+			return false
+		}
+		fmt.Print(color.YellowString("Warning: template '%s' was removed in the meantime\n", id))
+		return true
+	})
+	if len(ids) == 0 {
+		fmt.Println(color.YellowString("No templates to delete."))
+		return nil
+	}
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	// Gather the templates in a new trash, so that the last deletion stays
+	// restorable until this one has fully succeeded
+	staged := stagedTrashPath()
+	if err := os.RemoveAll(staged); err != nil {
+		return fmt.Errorf(color.RedString("Error: emptying trash: %v", err))
+	}
+	for i, id := range ids {
+		if err := moveTemplate(ctx, templatePath(id), trashedTemplatePath(staged, id)); err != nil {
+			for _, moved := range ids[:i] {
+				if moveTemplate(context.WithoutCancel(ctx), trashedTemplatePath(staged, moved), templatePath(moved)) != nil {
+					return fmt.Errorf(color.RedString("Error: deleting templates: %v; template '%s' was left in %q", err, moved, trashedTemplatePath(staged, moved)))
+				}
+			}
+			os.RemoveAll(staged)
+			return fmt.Errorf(color.RedString("Error: deleting templates: %v", err))
+		}
+	}
+
+	trash := &templateTrash{DeletedAt: time.Now().UTC(), Templates: map[string]*registryEntry{}, Aliases: map[string]string{}}
+	for _, id := range ids {
+		trash.Templates[id] = registry.Templates[id]
+		delete(registry.Templates, id)
+		removeEmptyParents(templatePath(id))
+	}
+	for alias, id := range registry.Aliases {
+		if _, deleted := trash.Templates[id]; deleted {
+			trash.Aliases[alias] = id
+			delete(registry.Aliases, alias)
+		}
+	}
+	if err := trash.save(staged); err != nil {
+		return err
+	}
+
+	// Only the last deletion can be restored
+	if err := os.RemoveAll(trashPath()); err != nil {
+		return fmt.Errorf(color.RedString("Error: emptying trash: %v", err))
+	}
+	if err := os.Rename(staged, trashPath()); err != nil {
+		return fmt.Errorf(color.RedString("Error: moving templates to the trash: %v", err))
+	}
+	if err := registry.save(); err != nil {
+		return err
+	}
+
+	fmt.Print(color.GreenString("Deleted %d template(s); undo with 'figo templates restore'\n", len(ids)))
+	return nil
+}
+
+// matchTemplates returns the installed templates named by names. A name with
+// glob characters matches every template whose identifier, a trailing part
+// of it, or its pre-namespace name matches; other names are resolved as
+// everywhere else.
+func matchTemplates(installed []string, names []string) ([]string, error) {
+	var ids []string
+	for _, name := range names {
+		if !strings.ContainsAny(name, `*?[`) {
+			id, err := resolveTemplate(installed, name)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+			continue
+		}
+
+		if _, err := path.Match(name, ""); err != nil {
+			return nil, fmt.Errorf(color.RedString("Error: invalid pattern %q", name))
+		}
+		matched := false
+		for _, id := range installed {
+			if matchesTemplate(name, id) {
+				ids = append(ids, id)
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf(color.RedString("Error: no templates match '%s'", name))
+		}
+	}
+
+	slices.Sort(ids)
+	return slices.Compact(ids), nil
+}
+
+func matchesTemplate(pattern string, id string) bool {
+	if matched, _ := path.Match(pattern, legacyTemplateName(id)); matched {
+		return true
+	}
+	segments := strings.Split(id, "/")
+	for i := range segments {
+		if matched, _ := path.Match(pattern, strings.Join(segments[i:], "/")); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreTemplates puts back the templates of the last deletion.
+func restoreTemplates(ctx context.Context) error {
+	unlock, err := lockTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	trash, err := loadTrash()
+	if err != nil {
+		return err
+	}
+	if trash == nil || len(trash.Templates) == 0 {
+		fmt.Println(color.YellowString("Nothing to restore."))
+		return nil
+	}
+
+	ids := make([]string, 0, len(trash.Templates))
+	for id := range trash.Templates {
+		if templateExists(id) {
+			return fmt.Errorf(color.RedString("Error: template '%s' has been installed again since it was deleted; delete it first to restore the old one", id))
+		}
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	var moveErr error
+	for _, id := range ids {
+		if moveErr = moveTemplate(ctx, trashedTemplatePath(trashPath(), id), templatePath(id)); moveErr != nil {
+			break
+		}
+		if entry := trash.Templates[id]; entry != nil {
+			registry.Templates[id] = entry
+		}
+		for alias, target := range trash.Aliases {
+			if _, taken := registry.Aliases[alias]; target == id && !taken {
+				if registry.Aliases == nil {
+					registry.Aliases = map[string]string{}
+				}
+				registry.Aliases[alias] = id
+			}
+		}
+		delete(trash.Templates, id)
+		fmt.Print(color.GreenString("Template '%s' restored\n", id))
+	}
+
+	if err := registry.save(); err != nil {
+		return err
+	}
+	if moveErr != nil {
+		if err := trash.save(trashPath()); err != nil {
+			return err
+		}
+		return fmt.Errorf(color.RedString("Error: restoring templates: %v", moveErr))
+	}
+	if err := os.RemoveAll(trashPath()); err != nil {
+		return fmt.Errorf(color.RedString("Error: emptying trash: %v", err))
+	}
+	return nil
+}
+
+func loadTrash() (*templateTrash, error) {
+	data, err := os.ReadFile(filepath.Join(trashPath(), trashManifestName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf(color.RedString("Error: reading trash: %v", err))
+	}
+
+	trash := &templateTrash{}
+	if err := json.Unmarshal(data, trash); err != nil {
+		return nil, fmt.Errorf(color.RedString("Error: parsing trash: %v", err))
+	}
+	return trash, nil
+}
+
+// save writes the trash manifest into the trash directory dir.
+func (t *templateTrash) save(dir string) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: encoding trash: %v", err))
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf(color.RedString("Error: creating trash directory: %v", err))
+	}
+	if err := os.WriteFile(filepath.Join(dir, trashManifestName), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf(color.RedString("Error: writing trash: %v", err))
+	}
+	return nil
+}
+
+// moveTemplate moves a template directory, copying it when src and dest
+// are on different file systems.
+func moveTemplate(ctx context.Context, src string, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	}
+
+	if err := copyFiles(ctx, src, dest, func(string) bool { return false }); err != nil {
+		os.RemoveAll(dest)
+		return err
+	}
+	return os.RemoveAll(src)
+}
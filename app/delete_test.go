@@ -0,0 +1,113 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestDeleteTemplates(t *testing.T) {
+	useTestHome(t)
+	ctx := context.Background()
+
+	ids := []string{"github.com/acme/templates/api", "github.com/acme/templates/cli", "github.com/acme/templates/web"}
+	registry := &templateRegistry{
+		Version:   registryVersion,
+		Templates: map[string]*registryEntry{},
+		Aliases:   map[string]string{"a": ids[0], "c": ids[1]},
+	}
+	for _, id := range ids {
+		writeTestTemplate(t, id)
+		registry.Templates[id] = &registryEntry{Source: "https://github.com/acme/templates"}
+	}
+	if err := registry.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name        string
+		run         func() error
+		wantInstall []string
+		wantAliases []string
+		wantErr     bool
+	}{
+		{
+			name:        "delete by pattern",
+			run:         func() error { return deleteTemplates(ctx, []string{"a*"}, deleteOptions{Yes: true}) },
+			wantInstall: ids[1:],
+			wantAliases: []string{"c"},
+		},
+		{
+			name:        "delete again",
+			run:         func() error { return deleteTemplates(ctx, []string{"cli"}, deleteOptions{Yes: true}) },
+			wantInstall: ids[2:],
+			wantAliases: nil,
+		},
+		{
+			name:        "restore the last deletion only",
+			run:         func() error { return restoreTemplates(ctx) },
+			wantInstall: ids[1:],
+			wantAliases: []string{"c"},
+		},
+		{
+			name:        "nothing left to restore",
+			run:         func() error { return restoreTemplates(ctx) },
+			wantInstall: ids[1:],
+			wantAliases: []string{"c"},
+		},
+		{
+			name:        "unknown template",
+			run:         func() error { return deleteTemplates(ctx, []string{"missing"}, deleteOptions{Yes: true}) },
+			wantInstall: ids[1:],
+			wantAliases: []string{"c"},
+			wantErr:     true,
+		},
+	}
+
+	for _, step := range steps {
+		if err := step.run(); (err != nil) != step.wantErr {
+			t.Fatalf("%s: error = %v, wantErr %v", step.name, err, step.wantErr)
+		}
+
+		installed, err := installedTemplates()
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(installed)
+		if !slices.Equal(installed, step.wantInstall) {
+			t.Errorf("%s: installed = %v, want %v", step.name, installed, step.wantInstall)
+		}
+
+		registry, err := loadRegistry()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var aliases []string
+		for alias := range registry.Aliases {
+			aliases = append(aliases, alias)
+		}
+		slices.Sort(aliases)
+		if !slices.Equal(aliases, step.wantAliases) {
+			t.Errorf("%s: aliases = %v, want %v", step.name, aliases, step.wantAliases)
+		}
+		for _, id := range step.wantInstall {
+			if registry.Templates[id] == nil {
+				t.Errorf("%s: template %s is not in the registry", step.name, id)
+			}
+		}
+	}
+}
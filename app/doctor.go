@@ -15,28 +15,27 @@
 package app
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"os"
 	"os/exec"
-	"strings"
 
 	"github.com/fatih/color"
 )
 
-func runDoctor() error {
+func runDoctor(ctx context.Context) error {
 
-	// Check Git version
-	gitVersionCmd := exec.Command("git", "--version")
+	// Check Git version; git is optional as templates can also be downloaded as archives
+	gitVersionCmd := exec.CommandContext(ctx, "git", "--version")
 	gitVersionOutput, err := gitVersionCmd.CombinedOutput()
 	if err != nil {
-		fmt.Print(color.RedString("Error: git is not installed or not available in PATH"))
+		fmt.Println(color.YellowString("Warning: git is not installed or not available in PATH"))
+		fmt.Println(color.YellowString("Templates can only be downloaded from hosts that offer archive downloads, and new projects won't be git repositories."))
 	} else {
 		fmt.Print(color.GreenString(string(gitVersionOutput)))
 	}
 
 	// Check Go version
-	goVersionCmd := exec.Command("go", "version")
+	goVersionCmd := exec.CommandContext(ctx, "go", "version")
 	goVersionOutput, err := goVersionCmd.CombinedOutput()
 	if err != nil {
 		fmt.Print(color.RedString("Error: go is not installed or not available in PATH"))
@@ -44,11 +43,26 @@ func runDoctor() error {
 		fmt.Print(color.GreenString(string(goVersionOutput)))
 	}
 
-	// Check if both Git and Go are available
+	// Check if Go is available
 	if err == nil {
 		fmt.Println(color.GreenString("All required tools are installed and accessible."))
+
+		// Show where templates are installed from
+		if sources := config.templateSources(); len(sources) > 0 {
+			fmt.Println(color.YellowString("Template sources, highest priority first:"))
+			for _, source := range sources {
+				if source.Ref != "" {
+					fmt.Printf("  %s@%s\n", source.URL, source.Ref)
+				} else {
+					fmt.Printf("  %s\n", source.URL)
+				}
+			}
+		} else {
+			fmt.Println(color.YellowString("Warning: No template sources are configured."))
+		}
+
 		// Check if template directory is empty
-		templates, err := listTemplates()
+		templates, err := listTemplates(ctx)
 		if err != nil {
 			return err
 		}
@@ -56,13 +70,14 @@ func runDoctor() error {
 		if len(templates) == 0 {
 			fmt.Println(color.YellowString("Warning: No templates found in the templates directory."))
 
-			// Prompt user to download default templates
-			fmt.Println("Do you want to download default templates? (y/n): ")
-			reader := bufio.NewReader(os.Stdin)
-			answer, _ := reader.ReadString('\n')
-			answer = strings.TrimSpace(answer)
+			// Prompt user to download templates from the configured sources
+			fmt.Println("Do you want to download templates from the configured sources? (y/n): ")
+			answer, err := readLine(ctx)
+			if err != nil {
+				return err
+			}
 			if answer == "y" || answer == "Y" {
-				err := downloadTemplates(defaultTemplatesRepoURL)
+				err := installTemplateSources(ctx)
 				if err != nil {
 					return err
 				}
@@ -73,7 +88,7 @@ func runDoctor() error {
 		fmt.Println(color.YellowString("Run 'figo help' for usage instructions."))
 	} else {
 		fmt.Println(color.RedString("Error: system environment check completed with errors."))
-		fmt.Println(color.YellowString("Please ensure that Git and Go are installed and available in your PATH."))
+		fmt.Println(color.YellowString("Please ensure that Go is installed and available in your PATH."))
 	}
 
 	return nil
@@ -0,0 +1,387 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// fetchRepository makes the templates at ref of the repository at url, or
+// at its default branch when ref is empty, available in a new temporary
+// directory. When subdirectory is set only that part of the repository is
+// checked out. The caller removes the returned directory.
+//
+// Repositories on hosts that offer archive downloads are downloaded as a
+// tar.gz over HTTP. Others, or when that fails, are fetched with git into
+// a bare cache under the user cache directory. The first fetch of a ref is
+// shallow; later ones are incremental.
+func fetchRepository(ctx context.Context, url string, ref string, subdirectory string) (string, templateSource, error) {
+	source := templateSource{URL: url, Ref: ref}
+
+	// Hosts that offer archive downloads don't need git at all
+	if archive, ok := archiveFor(ctx, url, ref); ok {
+		repoDir, revision, err := downloadArchive(ctx, archive, subdirectory)
+		if err == nil {
+			source.Revision = revision
+			return repoDir, source, nil
+		}
+		if ctx.Err() != nil || !gitInstalled() {
+			return "", source, fmt.Errorf(color.RedString("Error: downloading archive: %v", err))
+		}
+		fmt.Print(color.YellowString("Warning: downloading archive failed, falling back to git: %v\n", err))
+	}
+
+	if !gitInstalled() {
+		return "", source, fmt.Errorf(color.RedString("Error: git is needed to download templates from %s but is not installed", url))
+	}
+
+	cacheDir, err := repositoryCacheDir(url)
+	if err != nil {
+		return "", source, err
+	}
+
+	unlock, err := lockPath(ctx, cacheDir+".lock", "fetching "+url)
+	if err != nil {
+		return "", source, err
+	}
+	defer unlock()
+
+	source.Revision, source.Tag, err = fetchIntoCache(ctx, cacheDir, url, ref)
+	if err != nil {
+		return "", source, err
+	}
+
+	// Check out into a directory of our own so concurrent downloads don't collide
+	repoDir, err := os.MkdirTemp("", "figo-repo-*")
+	if err != nil {
+		return "", source, fmt.Errorf(color.RedString("Error: creating temporary directory: %v", err))
+	}
+
+	if err := checkoutFromCache(ctx, cacheDir, source.Revision, subdirectory, repoDir); err != nil {
+		os.RemoveAll(repoDir)
+		return "", source, err
+	}
+	return repoDir, source, nil
+}
+
+// repositoryCacheDir returns the bare repository that caches url.
+func repositoryCacheDir(url string) (string, error) {
+	cacheRoot, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf(color.RedString("Error: locating cache directory: %v", err))
+	}
+
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(cacheRoot, metaDataDirectoryname, "repos", hex.EncodeToString(sum[:8])+".git"), nil
+}
+
+// fetchIntoCache fetches ref from url into the bare repository at cacheDir
+// and returns the commit it resolved to, along with the tag name when ref is
+// a tag.
+func fetchIntoCache(ctx context.Context, cacheDir, url, ref string) (commit string, tag string, err error) {
+	if _, statErr := os.Stat(cacheDir); os.IsNotExist(statErr) {
+		// Don't keep a cache around for a repository we never managed to fetch
+		defer func() {
+			if err != nil {
+				os.RemoveAll(cacheDir)
+			}
+		}()
+
+		if err := initCache(ctx, cacheDir); err != nil {
+			return "", "", err
+		}
+	}
+
+	target := ref
+	if target == "" {
+		target = "HEAD"
+	}
+
+	// The commit we fetched last time is kept under a ref of our own, both so
+	// that it survives garbage collection and so that we know whether this
+	// fetch can build on it
+	sum := sha256.Sum256([]byte(target))
+	cacheRef := "refs/figo/" + hex.EncodeToString(sum[:8])
+
+	args := []string{"fetch", "--quiet", "--no-tags"}
+	if _, err := gitOutput(ctx, cacheDir, "rev-parse", "--verify", "--quiet", cacheRef); err != nil {
+		args = append(args, "--depth", "1")
+	}
+	args = append(args, url, target)
+
+	if err := gitFetch(ctx, cacheDir, url, args); err == nil {
+		commit, err = gitOutput(ctx, cacheDir, "rev-parse", "FETCH_HEAD^{commit}")
+		if err != nil {
+			return "", "", err
+		}
+		tag = fetchedTag(cacheDir)
+	} else {
+		if ref == "" || ctx.Err() != nil {
+			return "", "", err
+		}
+		// Servers may refuse to hand out arbitrary or abbreviated commits, so
+		// fall back to fetching every branch and tag and resolving ref locally
+		if commit, tag, err = fetchAllAndResolve(ctx, cacheDir, url, ref); err != nil {
+			return "", "", err
+		}
+	}
+
+	if _, err := gitOutput(ctx, cacheDir, "update-ref", cacheRef, commit); err != nil {
+		return "", "", err
+	}
+	return commit, tag, nil
+}
+
+func initCache(ctx context.Context, cacheDir string) error {
+	if err := os.MkdirAll(filepath.Dir(cacheDir), 0755); err != nil {
+		return fmt.Errorf(color.RedString("Error: creating cache directory: %v", err))
+	}
+	_, err := gitOutput(ctx, ".", "init", "--quiet", "--bare", cacheDir)
+	return err
+}
+
+// fetchHistory fetches the full history of revision from url into the
+// repository cache at cacheDir, creating the cache if needed.
+func fetchHistory(ctx context.Context, cacheDir, url, revision string) error {
+	if _, err := os.Stat(cacheDir); os.IsNotExist(err) {
+		if err := initCache(ctx, cacheDir); err != nil {
+			return err
+		}
+	}
+
+	args := []string{"fetch", "--quiet", "--no-tags"}
+	if _, err := os.Stat(filepath.Join(cacheDir, "shallow")); err == nil {
+		args = append(args, "--unshallow")
+	}
+	args = append(args, url, revision)
+	if err := gitFetch(ctx, cacheDir, url, args); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		// Servers may refuse to hand out commits by hash
+		_, _, err = fetchAllAndResolve(ctx, cacheDir, url, revision)
+		return err
+	}
+	return nil
+}
+
+func fetchAllAndResolve(ctx context.Context, cacheDir, url, ref string) (string, string, error) {
+	args := []string{"fetch", "--quiet"}
+	if _, err := os.Stat(filepath.Join(cacheDir, "shallow")); err == nil {
+		args = append(args, "--unshallow")
+	}
+	args = append(args, url, "+refs/heads/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*")
+
+	if err := gitFetch(ctx, cacheDir, url, args); err != nil {
+		return "", "", err
+	}
+
+	for _, candidate := range []string{"refs/tags/" + ref, "refs/remotes/origin/" + ref, ref} {
+		commit, err := gitOutput(ctx, cacheDir, "rev-parse", "--verify", "--quiet", candidate+"^{commit}")
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(candidate, "refs/tags/") {
+			return commit, ref, nil
+		}
+		return commit, "", nil
+	}
+	return "", "", fmt.Errorf(color.RedString("Error: ref '%s' not found in repository", ref))
+}
+
+// gitFetch runs git fetch from url with whatever credentials it needs.
+func gitFetch(ctx context.Context, cacheDir, url string, args []string) error {
+	authArgs, env, err := gitAuth(url)
+	if err != nil {
+		return err
+	}
+
+	if err := runCommandEnv(ctx, "git", append(authArgs, args...), env, cacheDir, "git fetch"); err != nil {
+		return authError(url, err)
+	}
+	return nil
+}
+
+// fetchedTag returns the tag name recorded in FETCH_HEAD, if the last fetch
+// was of a tag.
+func fetchedTag(cacheDir string) string {
+	data, err := os.ReadFile(filepath.Join(cacheDir, "FETCH_HEAD"))
+	if err != nil {
+		return ""
+	}
+
+	// Lines look like "<sha>\t\ttag 'v1.0.0' of <url>"
+	line, _, _ := strings.Cut(string(data), "\n")
+	_, description, _ := strings.Cut(line, "\t\t")
+	if name, ok := strings.CutPrefix(description, "tag '"); ok {
+		name, _, _ = strings.Cut(name, "'")
+		return name
+	}
+	return ""
+}
+
+// checkoutFromCache writes the tree of commit, or only its subdirectory when
+// one is given, into dest.
+func checkoutFromCache(ctx context.Context, cacheDir, commit, subdirectory, dest string) error {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout("git archive"))
+	defer cancel()
+
+	args := []string{"archive", "--format=tar", commit}
+	if subdirectory != "" {
+		args = append(args, "--", subdirectory)
+	}
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = cacheDir
+	setProcessGroup(cmd)
+	stderr := newTailBuffer(commandOutputTailLines)
+	cmd.Stderr = stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: running git archive: %v", err))
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf(color.RedString("Error: running git archive: %v", err))
+	}
+
+	extractErr := extractTar(stdout, dest)
+	// Drain the pipe so git never blocks on a reader that gave up early
+	io.Copy(io.Discard, stdout)
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf(color.RedString("Error: running git archive: %v\n%s", err, stderr))
+	}
+	return extractErr
+}
+
+// extractTar unpacks the tar stream r into dest, refusing entries that would
+// land outside of it.
+func extractTar(r io.Reader, dest string) error {
+	_, err := extractTarEntries(r, dest, func(name string) string { return name })
+	return err
+}
+
+// extractTarEntries is extractTar with entries renamed by rename, which
+// returns "" for entries to leave out. It returns the commit recorded in
+// the archive by git archive, if any.
+func extractTarEntries(r io.Reader, dest string, rename func(name string) string) (string, error) {
+	var commit string
+
+	reader := tar.NewReader(r)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return commit, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf(color.RedString("Error: reading archive: %v", err))
+		}
+
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			commit = header.PAXRecords["comment"]
+			continue
+		}
+
+		entryName := rename(header.Name)
+		if strings.Trim(entryName, "/") == "" {
+			continue
+		}
+		name := filepath.FromSlash(entryName)
+		if !filepath.IsLocal(name) {
+			return "", fmt.Errorf(color.RedString("Error: archive entry %q is outside the destination", header.Name))
+		}
+		target := filepath.Join(dest, name)
+		if err := checkExtractTarget(dest, name); err != nil {
+			return "", fmt.Errorf(color.RedString("Error: archive entry %q: %v", header.Name, err))
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return "", fmt.Errorf(color.RedString("Error: creating directory %q: %v", target, err))
+			}
+		case tar.TypeReg:
+			if err := writeTarFile(reader, target, header.FileInfo().Mode()); err != nil {
+				return "", err
+			}
+		case tar.TypeSymlink:
+			// Links may only point at other entries of the archive
+			linkTarget := filepath.Join(filepath.Dir(name), filepath.FromSlash(header.Linkname))
+			if filepath.IsAbs(filepath.FromSlash(header.Linkname)) || !filepath.IsLocal(linkTarget) {
+				return "", fmt.Errorf(color.RedString("Error: archive entry %q links to %q, outside the destination", header.Name, header.Linkname))
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return "", fmt.Errorf(color.RedString("Error: creating directory %q: %v", filepath.Dir(target), err))
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return "", fmt.Errorf(color.RedString("Error: creating symlink %q: %v", target, err))
+			}
+		case tar.TypeLink:
+			return "", fmt.Errorf(color.RedString("Error: archive entry %q is a hard link, which is not supported", header.Name))
+		}
+	}
+}
+
+// checkExtractTarget makes sure that writing the entry name into dest can't
+// go through a symlink, which could lead outside of dest: neither the entry
+// itself nor any directory above it may be one.
+func checkExtractTarget(dest string, name string) error {
+	current := dest
+	for _, segment := range strings.Split(name, string(filepath.Separator)) {
+		current = filepath.Join(current, segment)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%q is a symlink", current)
+		}
+	}
+	return nil
+}
+
+func writeTarFile(r io.Reader, target string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf(color.RedString("Error: creating directory %q: %v", filepath.Dir(target), err))
+	}
+
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: creating file %q: %v", target, err))
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return fmt.Errorf(color.RedString("Error: writing file %q: %v", target, err))
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf(color.RedString("Error: writing file %q: %v", target, err))
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetchRepository(t *testing.T) {
+	repo := initTestRepo(t, map[string]string{
+		"api/go.mod": "module example.com/api\n",
+		"cli/go.mod": "module example.com/cli\n",
+	})
+	runTestGit(t, repo, "tag", "v1")
+	tagged := testRevision(t, repo)
+	writeTestFiles(t, repo, map[string]string{"api/main.go": "package main\n"})
+	commitTestRepo(t, repo, "add main")
+	head := testRevision(t, repo)
+
+	tests := []struct {
+		name         string
+		ref          string
+		subdirectory string
+		wantRevision string
+		wantTag      string
+		wantFiles    []string
+		wantMissing  []string
+		wantErr      bool
+	}{
+		{name: "default branch", wantRevision: head, wantFiles: []string{"api/main.go", "cli/go.mod"}},
+		{name: "tag", ref: "v1", wantRevision: tagged, wantTag: "v1", wantFiles: []string{"api/go.mod"}, wantMissing: []string{"api/main.go"}},
+		{name: "branch", ref: "main", wantRevision: head, wantFiles: []string{"api/main.go"}},
+		{name: "abbreviated commit", ref: tagged[:10], wantRevision: tagged, wantFiles: []string{"api/go.mod"}, wantMissing: []string{"api/main.go"}},
+		{name: "subdirectory", subdirectory: "api", wantRevision: head, wantFiles: []string{"api/main.go"}, wantMissing: []string{"cli/go.mod"}},
+		{name: "unknown ref", ref: "v9", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestHome(t)
+
+			repoDir, source, err := fetchRepository(context.Background(), repo, tt.ref, tt.subdirectory)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchRepository() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				// A cache that was never fetched into is not kept
+				cacheDir, _ := repositoryCacheDir(repo)
+				if _, err := os.Stat(cacheDir); !os.IsNotExist(err) {
+					t.Errorf("cache %s was kept after a failed fetch", cacheDir)
+				}
+				return
+			}
+			defer os.RemoveAll(repoDir)
+
+			if source.Revision != tt.wantRevision || source.Tag != tt.wantTag || source.Ref != tt.ref {
+				t.Errorf("fetchRepository() source = %+v, want revision %s, tag %q and ref %q", source, tt.wantRevision, tt.wantTag, tt.ref)
+			}
+			for _, name := range tt.wantFiles {
+				if _, err := os.Stat(filepath.Join(repoDir, name)); err != nil {
+					t.Errorf("%s was not checked out: %v", name, err)
+				}
+			}
+			for _, name := range tt.wantMissing {
+				if _, err := os.Stat(filepath.Join(repoDir, name)); err == nil {
+					t.Errorf("%s was checked out", name)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchRepositoryCache(t *testing.T) {
+	useTestHome(t)
+	ctx := context.Background()
+
+	repo := initTestRepo(t, map[string]string{"go.mod": "module example.com/a\n"})
+	first := testRevision(t, repo)
+
+	repoDir, _, err := fetchRepository(ctx, repo, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(repoDir)
+
+	cacheDir, err := repositoryCacheDir(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "shallow")); err != nil {
+		t.Errorf("first fetch was not shallow: %v", err)
+	}
+
+	// Later fetches build on the cached history
+	writeTestFiles(t, repo, map[string]string{"main.go": "package main\n"})
+	commitTestRepo(t, repo, "add main")
+	repoDir, source, err := fetchRepository(ctx, repo, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(repoDir)
+
+	if source.Revision != testRevision(t, repo) {
+		t.Errorf("second fetch revision = %s, want %s", source.Revision, testRevision(t, repo))
+	}
+	if _, err := gitOutput(ctx, cacheDir, "cat-file", "-e", first+"^{commit}"); err != nil {
+		t.Errorf("cache lost the first commit: %v", err)
+	}
+}
+
+func TestFetchedTag(t *testing.T) {
+	tests := []struct {
+		fetchHead string
+		want      string
+	}{
+		{fetchHead: "0123abcd\t\ttag 'v1.0.0' of /srv/templates\n", want: "v1.0.0"},
+		{fetchHead: "0123abcd\t\tbranch 'main' of /srv/templates\n", want: ""},
+		{fetchHead: "0123abcd\t\t'HEAD' of /srv/templates\n", want: ""},
+		{fetchHead: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		cacheDir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(cacheDir, "FETCH_HEAD"), []byte(tt.fetchHead), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := fetchedTag(cacheDir); got != tt.want {
+			t.Errorf("fetchedTag(%q) = %q, want %q", tt.fetchHead, got, tt.want)
+		}
+	}
+}
+
+// tarEntry is an entry of a test archive.
+type tarEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	content  string
+}
+
+func buildTar(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:     entry.name,
+			Typeflag: entry.typeflag,
+			Linkname: entry.linkname,
+			Mode:     0644,
+			Size:     int64(len(entry.content)),
+		}
+		if entry.typeflag != tar.TypeReg {
+			header.Size = 0
+		}
+		if entry.typeflag == tar.TypeDir {
+			header.Mode = 0755
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if entry.typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(entry.content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestExtractTar(t *testing.T) {
+	outside := t.TempDir()
+
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		wantErr bool
+		// files are expected in the destination, with their contents
+		files map[string]string
+	}{
+		{
+			name: "files and directories",
+			entries: []tarEntry{
+				{name: "dir/", typeflag: tar.TypeDir},
+				{name: "dir/a.txt", typeflag: tar.TypeReg, content: "a"},
+				{name: "b.txt", typeflag: tar.TypeReg, content: "b"},
+			},
+			files: map[string]string{"dir/a.txt": "a", "b.txt": "b"},
+		},
+		{
+			name:    "parent traversal",
+			entries: []tarEntry{{name: "../evil.txt", typeflag: tar.TypeReg, content: "x"}},
+			wantErr: true,
+		},
+		{
+			name:    "nested parent traversal",
+			entries: []tarEntry{{name: "dir/../../evil.txt", typeflag: tar.TypeReg, content: "x"}},
+			wantErr: true,
+		},
+		{
+			name:    "absolute name",
+			entries: []tarEntry{{name: filepath.ToSlash(filepath.Join(outside, "evil.txt")), typeflag: tar.TypeReg, content: "x"}},
+			wantErr: true,
+		},
+		{
+			name: "symlink to an absolute path followed by a file through it",
+			entries: []tarEntry{
+				{name: "l", typeflag: tar.TypeSymlink, linkname: outside},
+				{name: "l/f", typeflag: tar.TypeReg, content: "x"},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "symlink out of the destination",
+			entries: []tarEntry{{name: "dir/l", typeflag: tar.TypeSymlink, linkname: "../../outside"}},
+			wantErr: true,
+		},
+		{
+			name: "file through a symlink inside the destination",
+			entries: []tarEntry{
+				{name: "dir/", typeflag: tar.TypeDir},
+				{name: "l", typeflag: tar.TypeSymlink, linkname: "dir"},
+				{name: "l/f", typeflag: tar.TypeReg, content: "x"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "file replacing a symlink",
+			entries: []tarEntry{
+				{name: "a.txt", typeflag: tar.TypeReg, content: "a"},
+				{name: "l", typeflag: tar.TypeSymlink, linkname: "a.txt"},
+				{name: "l", typeflag: tar.TypeReg, content: "x"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "hard link",
+			entries: []tarEntry{
+				{name: "a.txt", typeflag: tar.TypeReg, content: "a"},
+				{name: "h", typeflag: tar.TypeLink, linkname: "a.txt"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "symlink inside the destination",
+			entries: []tarEntry{
+				{name: "dir/a.txt", typeflag: tar.TypeReg, content: "a"},
+				{name: "dir/l", typeflag: tar.TypeSymlink, linkname: "a.txt"},
+			},
+			files: map[string]string{"dir/a.txt": "a", "dir/l": "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := t.TempDir()
+			err := extractTar(buildTar(t, tt.entries), dest)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("extractTar() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			for name, want := range tt.files {
+				got, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(name)))
+				if err != nil {
+					t.Fatalf("reading %s: %v", name, err)
+				}
+				if string(got) != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+
+			entries, err := os.ReadDir(outside)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) > 0 {
+				t.Fatalf("extractTar() wrote %s outside of the destination", entries[0].Name())
+			}
+		})
+	}
+}
@@ -0,0 +1,444 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// templateAnswers are the values a template is rendered with in a test
+// case, read from testdata/<case>/answers.json.
+type templateAnswers struct {
+	ProjectName string         `json:"project_name"`
+	Variables   map[string]any `json:"variables"`
+}
+
+// testOptions are the settings of templates test.
+type testOptions struct {
+	// Update rewrites the expected output instead of comparing with it
+	Update bool
+	// Build also runs go build on the rendered output
+	Build bool
+}
+
+// renderedFile is a file of a project rendered in memory.
+type renderedFile struct {
+	data []byte
+	mode os.FileMode
+}
+
+// testTemplates renders the templates in dirs with the answers of each of
+// their test cases and compares the result with the expected output checked
+// in next to the answers, in testdata/<case>/expected.
+func testTemplates(ctx context.Context, dirs []string, opts testOptions) error {
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	total, failed := 0, 0
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf(color.RedString("Error: '%s' is not a directory", dir))
+		}
+
+		candidates, err := findTemplates(dir, filepath.Base(dir))
+		if err != nil {
+			return err
+		}
+		for _, candidate := range candidates {
+			cases, err := testCases(candidate.dir)
+			if err != nil {
+				return err
+			}
+			for _, testCase := range cases {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				total++
+				if !runTestCase(ctx, candidate.dir, testCase, opts) {
+					failed++
+				}
+			}
+		}
+	}
+
+	switch {
+	case total == 0:
+		return fmt.Errorf(color.RedString("Error: no test cases found; add testdata/<case>/%s to a template", answersFileName))
+	case failed > 0:
+		return fmt.Errorf(color.RedString("Error: %d of %d test case(s) failed", failed, total))
+	case opts.Update:
+		fmt.Print(color.GreenString("Updated the expected output of %d test case(s)\n", total))
+		return nil
+	}
+	fmt.Print(color.GreenString("All %d test case(s) passed\n", total))
+	return nil
+}
+
+// testCases lists the test cases of the template in dir: the directories
+// of its testdata folder that have an answers file.
+func testCases(dir string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, testdataDirName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf(color.RedString("Error: reading test cases: %v", err))
+	}
+
+	var cases []string
+	for _, entry := range entries {
+		if entry.IsDir() && exists(filepath.Join(dir, testdataDirName, entry.Name(), answersFileName)) {
+			cases = append(cases, entry.Name())
+		}
+	}
+	return cases, nil
+}
+
+// runTestCase runs a single test case and reports whether it passed.
+func runTestCase(ctx context.Context, templateDir string, testCase string, opts testOptions) bool {
+	caseDir := filepath.Join(templateDir, testdataDirName, testCase)
+	expectedDir := filepath.Join(caseDir, expectedDirName)
+
+	fail := func(format string, args ...any) bool {
+		fmt.Print(color.RedString("FAIL %s\n", caseDir))
+		fmt.Printf("  "+format+"\n", args...)
+		return false
+	}
+
+	files, values, err := renderTestCase(templateDir, caseDir)
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	if opts.Update {
+		if err := writeGolden(expectedDir, files); err != nil {
+			return fail("%v", err)
+		}
+		fmt.Print(color.YellowString("UPDATED %s\n", caseDir))
+	} else {
+		expected, err := readGolden(expectedDir)
+		if os.IsNotExist(err) {
+			return fail("no expected output in %s; run 'figo templates test --update' to create it", expectedDir)
+		}
+		if err != nil {
+			return fail("%v", err)
+		}
+		if diffs := compareOutput(expected, files); len(diffs) > 0 {
+			fmt.Print(color.RedString("FAIL %s\n", caseDir))
+			for _, diff := range diffs {
+				fmt.Print(diff)
+			}
+			return false
+		}
+	}
+
+	if opts.Build {
+		if err := buildTestCase(ctx, files, values); err != nil {
+			return fail("build failed: %v", err)
+		}
+	}
+
+	if !opts.Update {
+		fmt.Print(color.GreenString("PASS %s\n", caseDir))
+	}
+	return true
+}
+
+// renderTestCase renders the template in templateDir in memory with the
+// answers of the test case in caseDir.
+func renderTestCase(templateDir string, caseDir string) (map[string]renderedFile, map[string]any, error) {
+	data, err := os.ReadFile(filepath.Join(caseDir, answersFileName))
+	if err != nil {
+		return nil, nil, fmt.Errorf(color.RedString("Error: reading answers: %v", err))
+	}
+
+	var answers templateAnswers
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&answers); err != nil {
+		return nil, nil, fmt.Errorf(color.RedString("Error: parsing %s: %v", filepath.Join(caseDir, answersFileName), err))
+	}
+	answers.ProjectName = cmp.Or(answers.ProjectName, "example")
+	if !isValidProjectName(answers.ProjectName) {
+		return nil, nil, fmt.Errorf(color.RedString("Error: invalid project name: %s", answers.ProjectName))
+	}
+
+	given := make(map[string]string, len(answers.Variables))
+	for name, value := range answers.Variables {
+		given[name] = fmt.Sprint(value)
+	}
+
+	manifest, err := loadManifest(templateDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	values, err := templateValues(manifest, answers.ProjectName, given, requireVariable)
+	if err != nil {
+		return nil, nil, err
+	}
+	jobs, err := planProject(templateDir, manifest, values)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := map[string]renderedFile{}
+	for _, job := range jobs {
+		relPath := filepath.ToSlash(job.dest)
+		// Test cases of templates that do not ignore them are left out, or
+		// the expected output would end up containing itself
+		if job.mode.IsDir() || relPath == testdataDirName || strings.HasPrefix(relPath, testdataDirName+"/") {
+			continue
+		}
+
+		text, err := os.ReadFile(job.src)
+		if err != nil {
+			return nil, nil, fmt.Errorf(color.RedString("Error: failed to read template file %q: %v", job.src, err))
+		}
+		if job.render {
+			rendered, err := renderString(job.src, string(text), values)
+			if err != nil {
+				return nil, nil, err
+			}
+			text = []byte(rendered)
+		}
+		files[relPath] = renderedFile{data: text, mode: job.mode.Perm()}
+	}
+	return files, values, nil
+}
+
+// readGolden reads the expected output of a test case.
+func readGolden(dir string) (map[string]renderedFile, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	files := map[string]renderedFile{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(relPath)] = renderedFile{data: data, mode: info.Mode().Perm()}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf(color.RedString("Error: reading expected output: %v", err))
+	}
+	return files, nil
+}
+
+// writeGolden replaces the expected output of a test case with files.
+func writeGolden(dir string, files map[string]renderedFile) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf(color.RedString("Error: removing expected output: %v", err))
+	}
+	return writeRenderedFiles(dir, files)
+}
+
+func writeRenderedFiles(dir string, files map[string]renderedFile) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf(color.RedString("Error: creating directory %q: %v", dir, err))
+	}
+	for relPath, file := range files {
+		path := filepath.Join(dir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf(color.RedString("Error: creating directory %q: %v", filepath.Dir(path), err))
+		}
+		if err := os.WriteFile(path, file.data, file.mode|0600); err != nil {
+			return fmt.Errorf(color.RedString("Error: writing %q: %v", path, err))
+		}
+	}
+	return nil
+}
+
+// compareOutput returns a diff for each file that differs between the
+// expected and the rendered output.
+func compareOutput(expected, actual map[string]renderedFile) []string {
+	paths := make([]string, 0, len(expected)+len(actual))
+	for relPath := range expected {
+		paths = append(paths, relPath)
+	}
+	for relPath := range actual {
+		if _, ok := expected[relPath]; !ok {
+			paths = append(paths, relPath)
+		}
+	}
+	sort.Strings(paths)
+
+	var diffs []string
+	for _, relPath := range paths {
+		want, inExpected := expected[relPath]
+		got, inActual := actual[relPath]
+		switch {
+		case !inActual:
+			diffs = append(diffs, fmt.Sprintf("  %s: expected but not rendered\n", relPath))
+		case !inExpected:
+			diffs = append(diffs, fmt.Sprintf("  %s: rendered but not expected\n", relPath))
+		case !bytes.Equal(want.data, got.data):
+			diffs = append(diffs, lineDiff("expected/"+relPath, "rendered/"+relPath, string(want.data), string(got.data)))
+		}
+	}
+	return diffs
+}
+
+// buildTestCase writes the rendered output of a test case to a temporary
+// directory and builds it.
+func buildTestCase(ctx context.Context, files map[string]renderedFile, values map[string]any) error {
+	dir, err := os.MkdirTemp("", "figo-test-")
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: creating build directory: %v", err))
+	}
+	defer os.RemoveAll(dir)
+
+	projectPath := filepath.Join(dir, fmt.Sprint(values[builtinProjectNameVariable]))
+	if err := writeRenderedFiles(projectPath, files); err != nil {
+		return err
+	}
+	if err := runGoModTidy(ctx, projectPath); err != nil {
+		return err
+	}
+	return runCommand(ctx, "go", []string{"build", "./..."}, projectPath, "go build")
+}
+
+// lineDiff returns a unified diff of two versions of a file.
+func lineDiff(oldName, newName, oldText, newText string) string {
+	a := splitLines(oldText)
+	b := splitLines(newText)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "%s\n%s\n", color.RedString("--- %s", oldName), color.GreenString("+++ %s", newName))
+	if len(a)*len(b) > maxDiffCells {
+		out.WriteString("  files differ, too large to compare line by line\n")
+		return out.String()
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type diffLine struct {
+		op   byte
+		text string
+	}
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+
+	// Print the changes with a few lines of context, in hunks
+	oldLine, newLine := 1, 1
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			oldLine++
+			newLine++
+			continue
+		}
+
+		from := max(start-diffContextLines, 0)
+		end := start
+		for k := start; k < len(lines) && k-end <= 2*diffContextLines; k++ {
+			if lines[k].op != ' ' {
+				end = k
+			}
+		}
+		to := min(end+diffContextLines+1, len(lines))
+
+		oldStart, newStart := oldLine-(start-from), newLine-(start-from)
+		oldCount, newCount := 0, 0
+		for _, line := range lines[from:to] {
+			if line.op != '+' {
+				oldCount++
+			}
+			if line.op != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&out, "%s\n", color.CyanString("@@ -%d,%d +%d,%d @@", oldStart, oldCount, newStart, newCount))
+		for _, line := range lines[from:to] {
+			text := strings.TrimSuffix(line.text, "\n")
+			switch line.op {
+			case '+':
+				out.WriteString(color.GreenString("+%s", text) + "\n")
+			case '-':
+				out.WriteString(color.RedString("-%s", text) + "\n")
+			default:
+				out.WriteString(" " + text + "\n")
+			}
+		}
+
+		for _, line := range lines[start:to] {
+			if line.op != '+' {
+				oldLine++
+			}
+			if line.op != '-' {
+				newLine++
+			}
+		}
+		start = to
+	}
+	return out.String()
+}
+
+// splitLines splits text into lines, keeping their line endings.
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
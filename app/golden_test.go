@@ -0,0 +1,117 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTestTemplates(t *testing.T) {
+	template := map[string]string{
+		"figo.json":     `{"variables": [{"name": "Port", "type": "int", "default": 8080}]}`,
+		"go.mod.tmpl":   "module example.com/{{.ProjectName}}\n",
+		"port.txt.tmpl": "{{.Port}}\n",
+	}
+	expected := map[string]string{
+		"testdata/basic/expected/go.mod":   "module example.com/api\n",
+		"testdata/basic/expected/port.txt": "1000000\n",
+	}
+	answers := map[string]string{
+		"testdata/basic/answers.json": `{"project_name": "api", "variables": {"Port": 1000000}}`,
+	}
+
+	tests := []struct {
+		name     string
+		files    []map[string]string
+		opts     testOptions
+		wantErr  bool
+		wantPort string
+	}{
+		{
+			name:  "matching output",
+			files: []map[string]string{template, answers, expected},
+		},
+		{
+			name:    "different output",
+			files:   []map[string]string{template, answers, expected, {"testdata/basic/expected/port.txt": "8080\n"}},
+			wantErr: true,
+		},
+		{
+			name:    "extra expected file",
+			files:   []map[string]string{template, answers, expected, {"testdata/basic/expected/README.md": "stale\n"}},
+			wantErr: true,
+		},
+		{
+			name:    "no expected output",
+			files:   []map[string]string{template, answers},
+			wantErr: true,
+		},
+		{
+			name:    "unknown variable",
+			files:   []map[string]string{template, expected, {"testdata/basic/answers.json": `{"variables": {"Host": "localhost"}}`}},
+			wantErr: true,
+		},
+		{
+			name:    "no test cases",
+			files:   []map[string]string{template},
+			wantErr: true,
+		},
+		{
+			name:     "update creates the expected output",
+			files:    []map[string]string{template, answers},
+			opts:     testOptions{Update: true},
+			wantPort: "1000000\n",
+		},
+		{
+			name:     "update replaces the expected output",
+			files:    []map[string]string{template, answers, expected, {"testdata/basic/expected/port.txt": "8080\n", "testdata/basic/expected/README.md": "stale\n"}},
+			opts:     testOptions{Update: true},
+			wantPort: "1000000\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, files := range tt.files {
+				writeTestFiles(t, dir, files)
+			}
+
+			err := testTemplates(context.Background(), []string{dir}, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("testTemplates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.opts.Update {
+				return
+			}
+
+			expectedDir := filepath.Join(dir, "testdata", "basic", "expected")
+			if port, err := os.ReadFile(filepath.Join(expectedDir, "port.txt")); err != nil || string(port) != tt.wantPort {
+				t.Errorf("expected port.txt = %q, %v, want %q", port, err, tt.wantPort)
+			}
+			if _, err := os.Stat(filepath.Join(expectedDir, "README.md")); !os.IsNotExist(err) {
+				t.Errorf("stale expected file was kept: %v", err)
+			}
+
+			// The updated output is what the next run compares with
+			if err := testTemplates(context.Background(), []string{dir}, testOptions{}); err != nil {
+				t.Errorf("testTemplates() after update error = %v", err)
+			}
+		})
+	}
+}
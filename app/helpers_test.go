@@ -0,0 +1,125 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useTestHome points figo at an empty home directory for the rest of the test.
+func useTestHome(t *testing.T) {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("APPDATA", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+
+	previous := templatesDirectory
+	templatesDirectory = getDefaultDirectory("templates")
+	t.Cleanup(func() { templatesDirectory = previous })
+}
+
+// writeTestFiles writes files, keyed by slash-separated paths, under dir.
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// writeTestTemplate installs a minimal template as id.
+func writeTestTemplate(t *testing.T, id string) {
+	t.Helper()
+
+	writeTestFiles(t, filepath.Join(templatesDirectory, filepath.FromSlash(id)), map[string]string{
+		"go.mod": "module example\n",
+	})
+}
+
+// initTestRepo creates a git repository holding files and returns its path.
+func initTestRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeTestFiles(t, dir, files)
+	runTestGit(t, dir, "init", "--quiet", "--initial-branch=main")
+	commitTestRepo(t, dir, "initial commit")
+	return dir
+}
+
+func commitTestRepo(t *testing.T, dir string, message string) {
+	t.Helper()
+
+	runTestGit(t, dir, "add", "--all")
+	runTestGit(t, dir, "-c", "user.name=figo", "-c", "user.email=figo@example.com", "commit", "--quiet", "--message", message)
+}
+
+func runTestGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+// testRevision returns the commit checked out in the repository at dir.
+func testRevision(t *testing.T, dir string) string {
+	t.Helper()
+
+	revision, err := gitOutput(context.Background(), dir, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(revision)
+}
+
+// captureStdout returns what f writes to standard output.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+
+	f()
+	w.Close()
+	return string(<-done)
+}
@@ -0,0 +1,125 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// ignoreRules are the patterns of a template's .figoignore file, naming
+// files that belong to the template, such as its README or test fixtures,
+// but are left out of generated projects. Patterns follow .gitignore: one
+// per line, # for comments, a trailing / for directories only, a leading !
+// to include a path again, and patterns containing a / match from the
+// template root while others match a name at any depth. ** is not
+// supported.
+type ignoreRules struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	glob     string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+	// line is where the pattern is in .figoignore
+	line int
+}
+
+// loadIgnoreRules reads the .figoignore file of the template in dir. A
+// template without one ignores nothing.
+func loadIgnoreRules(dir string) (*ignoreRules, error) {
+	rules, invalid, err := readIgnoreFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf(color.RedString("Error: %s:%d: invalid pattern %q", ignoreFileName, invalid[0].line, invalid[0].glob))
+	}
+	return rules, nil
+}
+
+// readIgnoreFile parses the .figoignore file of the template in dir. Lines
+// that are not valid patterns are returned separately, as written.
+func readIgnoreFile(dir string) (*ignoreRules, []ignorePattern, error) {
+	rules := &ignoreRules{}
+	var invalid []ignorePattern
+
+	file, err := os.Open(filepath.Join(dir, ignoreFileName))
+	if os.IsNotExist(err) {
+		return rules, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf(color.RedString("Error: reading %s: %v", ignoreFileName, err))
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern := ignorePattern{line: lineNumber}
+		line, pattern.negate = strings.CutPrefix(line, "!")
+		line, pattern.dirOnly = strings.CutSuffix(line, "/")
+		pattern.anchored = strings.Contains(line, "/")
+		pattern.glob = strings.TrimPrefix(line, "/")
+
+		if _, err := path.Match(pattern.glob, ""); err != nil || pattern.glob == "" || strings.Contains(pattern.glob, "**") {
+			invalid = append(invalid, ignorePattern{glob: scanner.Text(), line: lineNumber})
+			continue
+		}
+		rules.patterns = append(rules.patterns, pattern)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf(color.RedString("Error: reading %s: %v", ignoreFileName, err))
+	}
+	return rules, invalid, nil
+}
+
+// ignored reports whether the file or directory at relPath, relative to the
+// template root, is left out of projects. The last matching pattern wins.
+func (r *ignoreRules) ignored(relPath string, isDir bool) bool {
+	relPath = filepath.ToSlash(relPath)
+
+	ignored := false
+	for _, pattern := range r.patterns {
+		if pattern.matches(relPath, isDir) {
+			ignored = !pattern.negate
+		}
+	}
+	return ignored
+}
+
+func (p ignorePattern) matches(relPath string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if p.anchored {
+		matched, _ := path.Match(p.glob, relPath)
+		return matched
+	}
+	matched, _ := path.Match(p.glob, path.Base(relPath))
+	return matched
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import "testing"
+
+func TestIgnoreRules(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{ignoreFileName: `# authors only
+README.md
+testdata/
+/docs/*.md
+*.log
+!keep.log
+`})
+
+	rules, err := loadIgnoreRules(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{path: "README.md", want: true},
+		{path: "cmd/README.md", want: true},
+		{path: "testdata", isDir: true, want: true},
+		{path: "testdata", want: false},
+		{path: "docs/guide.md", want: true},
+		{path: "api/docs/guide.md", want: false},
+		{path: "build/out.log", want: true},
+		{path: "keep.log", want: false},
+		{path: "main.go", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := rules.ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestLoadIgnoreRulesInvalid(t *testing.T) {
+	for _, pattern := range []string{"[", "docs/**/*.md", "/"} {
+		dir := t.TempDir()
+		writeTestFiles(t, dir, map[string]string{ignoreFileName: pattern + "\n"})
+		if _, err := loadIgnoreRules(dir); err == nil {
+			t.Errorf("loadIgnoreRules() accepted %q", pattern)
+		}
+	}
+
+	rules, err := loadIgnoreRules(t.TempDir())
+	if err != nil || rules.ignored("README.md", false) {
+		t.Errorf("loadIgnoreRules() without %s = %+v, %v", ignoreFileName, rules, err)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// templateInfo is everything known about an installed template.
+type templateInfo struct {
+	ID          string             `json:"id"`
+	Description string             `json:"description,omitempty"`
+	Tags        []string           `json:"tags,omitempty"`
+	Source      *registryEntry     `json:"source,omitempty"`
+	Go          string             `json:"go,omitempty"`
+	Variables   []templateVariable `json:"variables"`
+	Hooks       []templateHook     `json:"hooks"`
+	// Files are the paths a new project gets, relative to its root, with
+	// directories ending in a slash
+	Files []string `json:"files"`
+}
+
+// showTemplateInfo prints the manifest, source and file tree of a template.
+// The file tree is rendered for a project called projectName, with
+// placeholders for variables that have no value.
+func showTemplateInfo(ctx context.Context, name string, projectName string, variables map[string]string, asJSON bool) error {
+	templates, err := installedTemplates()
+	if err != nil {
+		return err
+	}
+	templateID, err := resolveTemplateByPriority(templates, name)
+	if err != nil {
+		return err
+	}
+	templateDir := templatePath(templateID)
+
+	manifest, err := loadManifest(templateDir)
+	if err != nil {
+		return err
+	}
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	values, err := templateValues(manifest, projectName, variables, placeholderVariable)
+	if err != nil {
+		return err
+	}
+	jobs, err := planProject(templateDir, manifest, values)
+	if err != nil {
+		return err
+	}
+
+	info := templateInfo{
+		ID:          templateID,
+		Description: manifest.Description,
+		Tags:        manifest.Tags,
+		Source:      registry.Templates[templateID],
+		Go:          manifest.Go,
+		Variables:   manifest.Variables,
+		Hooks:       manifest.Hooks,
+	}
+	if info.Description == "" && info.Source != nil {
+		info.Description = info.Source.Description
+	}
+	for _, job := range jobs {
+		file := filepath.ToSlash(job.dest)
+		if job.mode.IsDir() {
+			file += "/"
+		}
+		info.Files = append(info.Files, file)
+	}
+	sort.Strings(info.Files)
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(info)
+	}
+
+	printTemplateInfo(info)
+	return nil
+}
+
+func printTemplateInfo(info templateInfo) {
+	fmt.Println(color.CyanString(info.ID))
+	if info.Description != "" {
+		fmt.Println(info.Description)
+	}
+	if len(info.Tags) > 0 {
+		fmt.Println(color.HiBlackString("Tags: %s", strings.Join(info.Tags, ", ")))
+	}
+	fmt.Println()
+
+	if entry := info.Source; entry != nil {
+		fmt.Println(color.YellowString("Source:"))
+		if entry.Source != "" {
+			fmt.Printf("  Repository:   %s\n", entry.Source)
+		}
+		if entry.Subdirectory != "" {
+			fmt.Printf("  Directory:    %s\n", entry.Subdirectory)
+		}
+		if entry.Ref != "" {
+			fmt.Printf("  Ref:          %s\n", entry.Ref)
+		}
+		if entry.Tag != "" {
+			fmt.Printf("  Tag:          %s\n", entry.Tag)
+		}
+		if entry.Revision != "" {
+			fmt.Printf("  Revision:     %s\n", shortRevision(entry.Revision))
+		}
+		if entry.SignedBy != "" {
+			fmt.Printf("  Signed by:    %s\n", entry.SignedBy)
+		}
+		fmt.Printf("  Installed:    %s\n", entry.InstalledAt.Local().Format("2006-01-02 15:04"))
+		fmt.Printf("  Updated:      %s\n", entry.UpdatedAt.Local().Format("2006-01-02 15:04"))
+		fmt.Println()
+	}
+
+	if info.Go != "" {
+		fmt.Printf("%s %s or later\n\n", color.YellowString("Go:"), info.Go)
+	}
+
+	fmt.Println(color.YellowString("Variables:"))
+	fmt.Printf("  %s %s\n", builtinProjectNameVariable, color.HiBlackString("(string, name of the project)"))
+	for _, variable := range info.Variables {
+		details := []string{variable.typeName()}
+		if value, err := variable.convert(variable.Default); variable.Default != nil && err == nil {
+			details = append(details, fmt.Sprintf("default %v", value))
+		} else {
+			details = append(details, "required")
+		}
+		if len(variable.Choices) > 0 {
+			details = append(details, "one of "+strings.Join(variable.Choices, ", "))
+		}
+
+		fmt.Printf("  %s %s", variable.Name, color.HiBlackString("(%s)", strings.Join(details, ", ")))
+		if variable.Description != "" {
+			fmt.Printf(" - %s", variable.Description)
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+
+	if len(info.Hooks) > 0 {
+		fmt.Println(color.YellowString("Hooks:"))
+		for _, hook := range info.Hooks {
+			fmt.Printf("  %s\n", hook.description())
+		}
+		fmt.Println()
+	}
+
+	fmt.Println(color.YellowString("Files:"))
+	printFileTree(info.Files)
+}
+
+// printFileTree prints sorted slash-separated paths as an indented tree.
+func printFileTree(files []string) {
+	type node struct {
+		name     string
+		children []*node
+	}
+
+	root := &node{}
+	index := map[string]*node{"": root}
+	for _, file := range files {
+		path := strings.TrimSuffix(file, "/")
+		if _, ok := index[path]; ok {
+			continue
+		}
+
+		parent := root
+		parts := strings.Split(path, "/")
+		for i := range parts {
+			key := strings.Join(parts[:i+1], "/")
+			child, ok := index[key]
+			if !ok {
+				child = &node{name: parts[i]}
+				if i < len(parts)-1 || strings.HasSuffix(file, "/") {
+					child.name += "/"
+				}
+				index[key] = child
+				parent.children = append(parent.children, child)
+			}
+			parent = child
+		}
+	}
+
+	var walk func(n *node, indent string)
+	walk = func(n *node, indent string) {
+		for i, child := range n.children {
+			branch, next := "├── ", "│   "
+			if i == len(n.children)-1 {
+				branch, next = "└── ", "    "
+			}
+			fmt.Printf("  %s%s%s\n", indent, branch, child.name)
+			walk(child, indent+next)
+		}
+	}
+	walk(root, "")
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestShowTemplateInfo(t *testing.T) {
+	useTestHome(t)
+	writeTestTemplate(t, "local/acme/api")
+	writeTestFiles(t, templatePath("local/acme/api"), map[string]string{
+		manifestFileName:                  `{"description": "HTTP API", "variables": [{"name": "Docker", "type": "bool"}, {"name": "Owner"}], "hooks": [{"run": ["go", "vet"]}]}`,
+		"cmd/{{.ProjectName}}/main.go":    "package main\n",
+		"{{.Owner}}.txt":                  "owner\n",
+		"{{if .Docker}}Dockerfile{{end}}": "FROM golang\n",
+	})
+
+	var err error
+	out := captureStdout(t, func() {
+		err = showTemplateInfo(context.Background(), "api", "svc", map[string]string{"Docker": "true"}, true)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var info templateInfo
+	if err := json.Unmarshal([]byte(out), &info); err != nil {
+		t.Fatalf("showTemplateInfo() printed invalid JSON %q: %v", out, err)
+	}
+	if info.ID != "local/acme/api" || info.Description != "HTTP API" || len(info.Variables) != 2 || len(info.Hooks) != 1 {
+		t.Errorf("showTemplateInfo() = %+v", info)
+	}
+	// Variables without a value are shown as placeholders
+	want := []string{"<Owner>.txt", "Dockerfile", "cmd/", "cmd/svc/", "cmd/svc/main.go", "go.mod"}
+	if !reflect.DeepEqual(info.Files, want) {
+		t.Errorf("showTemplateInfo() files = %v, want %v", info.Files, want)
+	}
+}
+
+func TestPrintFileTree(t *testing.T) {
+	out := captureStdout(t, func() {
+		printFileTree([]string{"cmd/", "cmd/app/main.go", "go.mod", "internal/"})
+	})
+
+	want := "  ├── cmd/\n" +
+		"  │   └── app/\n" +
+		"  │       └── main.go\n" +
+		"  ├── go.mod\n" +
+		"  └── internal/\n"
+	if out != want {
+		t.Errorf("printFileTree() printed\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestPrintTemplateInfoSource(t *testing.T) {
+	tests := []struct {
+		name           string
+		source         *registryEntry
+		wantRepository bool
+	}{
+		{name: "installed", source: &registryEntry{Source: "https://github.com/acme/templates"}, wantRepository: true},
+		{name: "captured", source: &registryEntry{}, wantRepository: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := captureStdout(t, func() {
+				printTemplateInfo(templateInfo{ID: "api", Source: tt.source})
+			})
+			if got := strings.Contains(out, "Repository:"); got != tt.wantRepository {
+				t.Errorf("printTemplateInfo() shows a repository = %v, want %v:\n%s", got, tt.wantRepository, out)
+			}
+			if !strings.Contains(out, "Installed:") {
+				t.Errorf("printTemplateInfo() doesn't show when the template was installed:\n%s", out)
+			}
+		})
+	}
+}
@@ -0,0 +1,616 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/fatih/color"
+)
+
+var (
+	moduleLinePattern = regexp.MustCompile(`(?m)^\s*module\s+\S`)
+	// templateErrorPattern finds the line in errors of templates named "t"
+	templateErrorPattern = regexp.MustCompile(`^template: t:(\d+):(?:\d+:)? (.*)$`)
+	templateLinePattern  = regexp.MustCompile(`\bt:(\d+)`)
+	scriptExtensions     = []string{".sh", ".bash", ".py", ".ps1", ".rb", ".pl"}
+	ansiPattern          = regexp.MustCompile("\x1b\\[[0-9;]*m")
+)
+
+// lintProblem is something wrong with a template, found by templates lint.
+type lintProblem struct {
+	path    string
+	line    int
+	message string
+}
+
+func (p lintProblem) String() string {
+	if p.line > 0 {
+		return fmt.Sprintf("%s:%d: %s", p.path, p.line, p.message)
+	}
+	return fmt.Sprintf("%s: %s", p.path, p.message)
+}
+
+// templateLinter checks a single template directory.
+type templateLinter struct {
+	dir      string
+	manifest *templateManifest
+	// defined holds the variables the template's files may refer to
+	defined map[string]bool
+	// used holds the variables something in the template refers to
+	used map[string]bool
+	// incomplete is set when some templated text could not be parsed, so
+	// that not every use of a variable is known
+	incomplete bool
+	// combinations are sets of variable values to try conditional paths with
+	combinations []map[string]any
+	problems     []lintProblem
+}
+
+// lintTemplates checks the templates in each of dirs, which may be template
+// directories or whole templates repositories, and prints every problem as
+// file:line. It fails when any problem is found.
+func lintTemplates(dirs []string) error {
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	var problems []lintProblem
+	checked := 0
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf(color.RedString("Error: '%s' is not a directory", dir))
+		}
+
+		candidates, err := findTemplates(dir, filepath.Base(dir))
+		if err != nil {
+			return err
+		}
+		if len(candidates) == 0 {
+			return fmt.Errorf(color.RedString("Error: no templates found in '%s'", dir))
+		}
+
+		for _, candidate := range candidates {
+			problems = append(problems, lintTemplate(candidate.dir)...)
+			checked++
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].path != problems[j].path {
+			return problems[i].path < problems[j].path
+		}
+		return problems[i].line < problems[j].line
+	})
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf(color.RedString("Error: found %d problem(s)", len(problems)))
+	}
+	fmt.Print(color.GreenString("Checked %d template(s), no problems found\n", checked))
+	return nil
+}
+
+// lintTemplate returns the problems of the template in dir.
+func lintTemplate(dir string) []lintProblem {
+	l := &templateLinter{dir: dir}
+	if !l.loadManifest() {
+		return l.problems
+	}
+
+	l.defined = map[string]bool{builtinProjectNameVariable: true}
+	l.used = map[string]bool{}
+	for _, variable := range l.manifest.Variables {
+		l.defined[variable.Name] = true
+	}
+
+	var err error
+	l.combinations, err = lintCombinations(l.manifest)
+	if err != nil {
+		l.report(manifestFileName, 0, "%s", plainError(err))
+		return l.problems
+	}
+
+	l.checkFiles()
+	l.checkGoMod()
+	l.checkHooks()
+	l.checkUnused()
+	return l.problems
+}
+
+func (l *templateLinter) report(relPath string, line int, format string, args ...any) {
+	l.problems = append(l.problems, lintProblem{
+		path:    filepath.Join(l.dir, relPath),
+		line:    line,
+		message: fmt.Sprintf(format, args...),
+	})
+}
+
+// loadManifest parses the manifest more strictly than creating a project
+// does, so that misspelled fields are caught too. It reports whether the
+// rest of the template can be checked.
+func (l *templateLinter) loadManifest() bool {
+	l.manifest = &templateManifest{}
+
+	data, err := os.ReadFile(filepath.Join(l.dir, manifestFileName))
+	if os.IsNotExist(err) {
+		return true
+	}
+	if err != nil {
+		l.report(manifestFileName, 0, "%v", err)
+		return false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(l.manifest); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			l.report(manifestFileName, lineAt(data, int(syntaxErr.Offset)), "invalid JSON: %v", err)
+		case errors.As(err, &typeErr):
+			l.report(manifestFileName, lineAt(data, int(typeErr.Offset)), "%v", err)
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+			l.report(manifestFileName, nthLine(data, field, 0), "unknown field %s", field)
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			l.report(manifestFileName, 0, "manifest is empty or truncated")
+		default:
+			l.report(manifestFileName, 0, "%v", err)
+		}
+		return false
+	}
+	if err := l.manifest.validate(); err != nil {
+		l.report(manifestFileName, 0, "%v", err)
+		return false
+	}
+	l.manifest.found = true
+	return true
+}
+
+// checkFiles walks the template, checking templated files and paths and
+// pointing out files that will not end up in projects as they are.
+func (l *templateLinter) checkFiles() {
+	ignore, invalid, err := readIgnoreFile(l.dir)
+	if err != nil {
+		l.report(ignoreFileName, 0, "%s", plainError(err))
+		return
+	}
+	for _, pattern := range invalid {
+		l.report(ignoreFileName, pattern.line, "invalid pattern %q", pattern.glob)
+	}
+	used := make([]bool, len(ignore.patterns))
+
+	err = filepath.Walk(l.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == l.dir {
+			return nil
+		}
+		relPath, err := filepath.Rel(l.dir, path)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() && shouldSkipDir(info.Name()) {
+			if info.Name() != ".git" {
+				l.report(relPath, 0, "%s directories are never copied into projects", info.Name())
+			}
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && shouldSkipProjectFile(relPath) {
+			return nil
+		}
+
+		for i, pattern := range ignore.patterns {
+			if pattern.matches(filepath.ToSlash(relPath), info.IsDir()) {
+				used[i] = true
+			}
+		}
+		if ignore.ignored(relPath, info.IsDir()) {
+			if relPath == "go.mod" || relPath == "go.mod"+templateFileSuffix {
+				l.report(ignoreFileName, 0, "%s is ignored, so projects will not be Go modules", relPath)
+			}
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		templated := strings.Contains(info.Name(), "{{")
+		rendered := !info.IsDir() && strings.HasSuffix(info.Name(), templateFileSuffix)
+		if !l.manifest.found {
+			if templated || rendered {
+				l.report(relPath, 0, "copied as it is, since only templates with a %s are rendered", manifestFileName)
+			}
+			return nil
+		}
+
+		if templated {
+			l.checkPath(relPath, info.Name())
+		}
+		if rendered {
+			l.checkFile(relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		l.report("", 0, "%v", err)
+	}
+
+	for i, pattern := range ignore.patterns {
+		if !used[i] {
+			l.report(ignoreFileName, pattern.line, "pattern matches no files")
+		}
+	}
+}
+
+// checkPath checks the templated name of a file or directory, and that it
+// is created for at least some values of the template's variables.
+func (l *templateLinter) checkPath(relPath string, name string) {
+	tmpl, ok := l.parse(relPath, 0, name)
+	if !ok {
+		return
+	}
+
+	for _, values := range l.combinations {
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, values); err != nil {
+			l.report(relPath, 0, "name cannot be rendered: %s", templateErrorMessage(err))
+			return
+		}
+		if strings.TrimSpace(out.String()) != "" {
+			return
+		}
+	}
+	l.report(relPath, 0, "never created: the name renders to nothing for every value of its variables")
+}
+
+// checkFile checks a file ending in .tmpl, and that it renders with the
+// template's default values.
+func (l *templateLinter) checkFile(relPath string) {
+	data, err := os.ReadFile(filepath.Join(l.dir, relPath))
+	if err != nil {
+		l.report(relPath, 0, "%v", err)
+		return
+	}
+
+	tmpl, ok := l.parse(relPath, 1, string(data))
+	if !ok {
+		return
+	}
+	if err := tmpl.Execute(io.Discard, l.combinations[0]); err != nil {
+		line, message := templateErrorLine(err)
+		l.report(relPath, line, "cannot be rendered: %s", message)
+	}
+}
+
+// parse parses text as a template, reporting syntax errors and references
+// to undefined variables. firstLine is the line text starts at in relPath,
+// or 0 when it is not part of the file's contents.
+func (l *templateLinter) parse(relPath string, firstLine int, text string) (*template.Template, bool) {
+	tmpl, err := template.New("t").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		line, message := templateErrorLine(err)
+		if firstLine == 0 {
+			line = 0
+		}
+		l.report(relPath, line, "template syntax error: %s", message)
+		l.incomplete = true
+		return nil, false
+	}
+
+	ok := true
+	walkFields(tmpl.Tree.Root, true, func(pos parse.Pos, name string) {
+		l.used[name] = true
+		if l.defined[name] {
+			return
+		}
+		line := 0
+		if firstLine > 0 {
+			line = lineAt([]byte(text), int(pos))
+		}
+		l.report(relPath, line, "undefined variable %q", name)
+		ok = false
+	})
+	return tmpl, ok
+}
+
+// checkGoMod checks that the template's go.mod declares a module.
+func (l *templateLinter) checkGoMod() {
+	for _, name := range []string{"go.mod", "go.mod" + templateFileSuffix} {
+		data, err := os.ReadFile(filepath.Join(l.dir, name))
+		if err != nil {
+			continue
+		}
+		if !moduleLinePattern.Match(data) {
+			l.report(name, 0, "no module line")
+		}
+	}
+}
+
+// checkHooks checks that the scripts hooks run are part of the projects
+// they run in.
+func (l *templateLinter) checkHooks() {
+	if len(l.manifest.Hooks) == 0 {
+		return
+	}
+	data, _ := os.ReadFile(filepath.Join(l.dir, manifestFileName))
+
+	// Everything a project may contain, for any of the values tried
+	created := map[string]bool{}
+	for _, values := range l.combinations {
+		jobs, err := planProject(l.dir, l.manifest, values)
+		if err != nil {
+			continue
+		}
+		for _, job := range jobs {
+			created[filepath.ToSlash(job.dest)] = true
+		}
+	}
+
+	for i, hook := range l.manifest.Hooks {
+		line := nthLine(data, `"run"`, i)
+		for j, arg := range hook.Run {
+			tmpl, err := template.New("t").Funcs(templateFuncs).Option("missingkey=error").Parse(arg)
+			if err != nil {
+				l.report(manifestFileName, line, "hook '%s': template syntax error: %s", hook.description(), templateErrorMessage(err))
+				l.incomplete = true
+				continue
+			}
+			undefined := false
+			walkFields(tmpl.Tree.Root, true, func(_ parse.Pos, name string) {
+				l.used[name] = true
+				if l.defined[name] {
+					return
+				}
+				l.report(manifestFileName, line, "hook '%s': undefined variable %q", hook.description(), name)
+				undefined = true
+			})
+			if undefined {
+				continue
+			}
+
+			var out bytes.Buffer
+			if err := tmpl.Execute(&out, l.combinations[0]); err != nil {
+				l.report(manifestFileName, line, "hook '%s': %s", hook.description(), templateErrorMessage(err))
+				continue
+			}
+			script, ok := hookScript(out.String(), j == 0)
+			if !ok {
+				continue
+			}
+
+			switch {
+			case !filepath.IsLocal(script):
+				l.report(manifestFileName, line, "hook '%s' runs %s, which is outside of the project", hook.description(), out.String())
+			case created[script]:
+			case exists(filepath.Join(l.dir, script)):
+				l.report(manifestFileName, line, "hook '%s' runs %s, which is left out of projects", hook.description(), out.String())
+			default:
+				l.report(manifestFileName, line, "hook '%s' runs %s, which does not exist", hook.description(), out.String())
+			}
+		}
+	}
+}
+
+// checkUnused points out variables that are asked for but that nothing in
+// the template refers to: no file, path, hook or other variable's default.
+func (l *templateLinter) checkUnused() {
+	if l.incomplete {
+		return
+	}
+
+	for _, variable := range l.manifest.Variables {
+		if variable.Default == nil {
+			continue
+		}
+		tmpl, err := template.New("t").Funcs(templateFuncs).Parse(fmt.Sprint(variable.Default))
+		if err != nil {
+			continue
+		}
+		walkFields(tmpl.Tree.Root, true, func(_ parse.Pos, name string) {
+			l.used[name] = true
+		})
+	}
+
+	data, _ := os.ReadFile(filepath.Join(l.dir, manifestFileName))
+	for _, variable := range l.manifest.Variables {
+		if !l.used[variable.Name] {
+			l.report(manifestFileName, nthLine(data, strconv.Quote(variable.Name), 0), "variable %q is never used", variable.Name)
+		}
+	}
+}
+
+// hookScript returns the path relative to the project of a file a hook
+// argument refers to, if it looks like one: an explicitly relative path, a
+// command given as a path, or a script.
+func hookScript(arg string, command bool) (string, bool) {
+	if arg == "" || strings.HasPrefix(arg, "-") || filepath.IsAbs(arg) || strings.Contains(arg, "://") || strings.Contains(arg, "...") {
+		return "", false
+	}
+
+	relative := strings.HasPrefix(arg, "./") || strings.HasPrefix(arg, "../")
+	if !relative && !(command && strings.Contains(arg, "/")) && !slices.Contains(scriptExtensions, filepath.Ext(arg)) {
+		return "", false
+	}
+	return filepath.ToSlash(filepath.Clean(arg)), true
+}
+
+// walkFields calls visit with each variable a template refers to. Inside
+// range and with, where dot is no longer the variables, only references
+// through $ are visited.
+func walkFields(node parse.Node, dotIsRoot bool, visit func(parse.Pos, string)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkFields(child, dotIsRoot, visit)
+		}
+	case *parse.ActionNode:
+		walkFields(n.Pipe, dotIsRoot, visit)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walkFields(cmd, dotIsRoot, visit)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkFields(arg, dotIsRoot, visit)
+		}
+	case *parse.ChainNode:
+		walkFields(n.Node, dotIsRoot, visit)
+	case *parse.FieldNode:
+		if dotIsRoot {
+			visit(n.Pos, n.Ident[0])
+		}
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			visit(n.Pos, n.Ident[1])
+		}
+	case *parse.IfNode:
+		walkFields(n.Pipe, dotIsRoot, visit)
+		walkFields(n.List, dotIsRoot, visit)
+		walkFields(n.ElseList, dotIsRoot, visit)
+	case *parse.RangeNode:
+		walkFields(n.Pipe, dotIsRoot, visit)
+		walkFields(n.List, false, visit)
+		walkFields(n.ElseList, dotIsRoot, visit)
+	case *parse.WithNode:
+		walkFields(n.Pipe, dotIsRoot, visit)
+		walkFields(n.List, false, visit)
+		walkFields(n.ElseList, dotIsRoot, visit)
+	case *parse.TemplateNode:
+		walkFields(n.Pipe, dotIsRoot, visit)
+	}
+}
+
+// lintCombinations returns the sets of variable values conditional paths
+// are tried with: every combination of the values that typically decide
+// them, starting with the defaults.
+func lintCombinations(manifest *templateManifest) ([]map[string]any, error) {
+	base, err := templateValues(manifest, "example", nil, placeholderVariable)
+	if err != nil {
+		return nil, err
+	}
+
+	combinations := []map[string]any{base}
+	for _, variable := range manifest.Variables {
+		var options []any
+		switch {
+		case variable.typeName() == "bool":
+			options = []any{false, true}
+		case len(variable.Choices) > 0:
+			for _, choice := range variable.Choices {
+				options = append(options, choice)
+			}
+		case variable.typeName() == "int":
+			options = []any{0, 1}
+		default:
+			options = []any{"", "<" + variable.Name + ">"}
+		}
+		options = slices.DeleteFunc(options, func(option any) bool { return option == base[variable.Name] })
+
+		var next []map[string]any
+		if len(combinations)*(len(options)+1) > maxLintCombinations {
+			next = combinations
+			for _, option := range options {
+				values := maps.Clone(base)
+				values[variable.Name] = option
+				next = append(next, values)
+			}
+		} else {
+			next = slices.Clone(combinations)
+			for _, values := range combinations {
+				for _, option := range options {
+					values := maps.Clone(values)
+					values[variable.Name] = option
+					next = append(next, values)
+				}
+			}
+		}
+		combinations = next
+	}
+	return combinations, nil
+}
+
+// templateErrorLine splits an error of a template named "t" into the line
+// it is on and the message.
+func templateErrorLine(err error) (int, string) {
+	match := templateErrorPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0, err.Error()
+	}
+	line, _ := strconv.Atoi(match[1])
+	return line, templateLinePattern.ReplaceAllString(match[2], "line $1")
+}
+
+func templateErrorMessage(err error) string {
+	_, message := templateErrorLine(err)
+	return message
+}
+
+// plainError returns the message of an error made for the terminal,
+// without colours or the "Error: " prefix.
+func plainError(err error) string {
+	return strings.TrimPrefix(ansiPattern.ReplaceAllString(err.Error(), ""), "Error: ")
+}
+
+// lineAt returns the line of data that offset is on.
+func lineAt(data []byte, offset int) int {
+	offset = min(offset, len(data))
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// nthLine returns the line of the n-th occurrence, counting from 0, of
+// text in data, or 0 when there is none.
+func nthLine(data []byte, text string, n int) int {
+	offset := 0
+	for i := 0; i <= n; i++ {
+		index := bytes.Index(data[offset:], []byte(text))
+		if index < 0 {
+			return 0
+		}
+		offset += index + len(text)
+	}
+	return lineAt(data, offset)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,222 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLintTemplate(t *testing.T) {
+	const manifest = `{
+  "variables": [
+    {"name": "Docker", "type": "bool", "default": false}
+  ]
+}
+`
+	base := map[string]string{
+		manifestFileName:                  manifest,
+		"go.mod.tmpl":                     "module example.com/{{.ProjectName}}\n",
+		"{{if .Docker}}Dockerfile{{end}}": "FROM golang\n",
+	}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{name: "clean"},
+		{
+			name:  "manifest does not parse",
+			files: map[string]string{manifestFileName: "{\n  \"description\": \"api\",\n}\n"},
+			want:  []string{"figo.json:3: invalid JSON: invalid character '}' looking for beginning of object key string"},
+		},
+		{
+			name:  "unknown manifest field",
+			files: map[string]string{manifestFileName: "{\n  \"descripton\": \"api\"\n}\n"},
+			want:  []string{`figo.json:2: unknown field "descripton"`},
+		},
+		{
+			name:  "invalid manifest",
+			files: map[string]string{manifestFileName: `{"variables": [{"name": "my-var"}]}`},
+			want:  []string{`figo.json: variable name "my-var" is not an identifier`},
+		},
+		{
+			name:  "undefined variable in a file",
+			files: map[string]string{"main.go.tmpl": "package main\n\n// {{.Name}}\n"},
+			want:  []string{`main.go.tmpl:3: undefined variable "Name"`},
+		},
+		{
+			name:  "undefined variable in a path",
+			files: map[string]string{"{{.Dir}}/main.go": "package main\n"},
+			want:  []string{`{{.Dir}}: undefined variable "Dir"`},
+		},
+		{
+			name:  "variable used through $ inside range",
+			files: map[string]string{"main.go.tmpl": "{{range .Docker}}{{.Name}}{{$.Missing}}{{end}}\n"},
+			want:  []string{`main.go.tmpl:1: undefined variable "Missing"`},
+		},
+		{
+			name:  "syntax error",
+			files: map[string]string{"main.go.tmpl": "package main\n\n{{if .Docker}}\n"},
+			want:  []string{"main.go.tmpl:4: template syntax error: unexpected EOF"},
+		},
+		{
+			name:  "file that cannot be rendered",
+			files: map[string]string{"main.go.tmpl": "package main\n\n{{index .ProjectName 10}}\n"},
+			want:  []string{`main.go.tmpl:3: cannot be rendered: executing "t" at <index .ProjectName 10>: error calling index: index out of range: 10`},
+		},
+		{
+			name:  "unreachable conditional file",
+			files: map[string]string{"{{if and .Docker (not .Docker)}}compose.yml{{end}}": "services:\n"},
+			want:  []string{"{{if and .Docker (not .Docker)}}compose.yml{{end}}: never created: the name renders to nothing for every value of its variables"},
+		},
+		{
+			name: "hook runs a missing script",
+			files: map[string]string{manifestFileName: `{
+  "variables": [{"name": "Docker", "type": "bool", "default": false}],
+  "hooks": [
+    {"name": "setup", "run": ["./scripts/setup.sh"]}
+  ]
+}`},
+			want: []string{"figo.json:4: hook 'setup' runs ./scripts/setup.sh, which does not exist"},
+		},
+		{
+			name: "hook runs an ignored script",
+			files: map[string]string{
+				manifestFileName: `{
+  "variables": [{"name": "Docker", "type": "bool", "default": false}],
+  "hooks": [
+    {"name": "setup", "run": ["sh", "scripts/setup.sh"]}
+  ]
+}`,
+				"scripts/setup.sh": "#!/bin/sh\n",
+				ignoreFileName:     "scripts/\n",
+			},
+			want: []string{"figo.json:4: hook 'setup' runs scripts/setup.sh, which is left out of projects"},
+		},
+		{
+			name: "hook runs a script outside the project",
+			files: map[string]string{manifestFileName: `{
+  "variables": [{"name": "Docker", "type": "bool", "default": false}],
+  "hooks": [{"run": ["../setup.sh"]}]
+}`},
+			want: []string{"figo.json:3: hook '../setup.sh' runs ../setup.sh, which is outside of the project"},
+		},
+		{
+			name: "hook with an undefined variable",
+			files: map[string]string{manifestFileName: `{
+  "variables": [{"name": "Docker", "type": "bool", "default": false}],
+  "hooks": [{"name": "tidy", "run": ["go", "mod", "{{.Mode}}"]}]
+}`},
+			want: []string{`figo.json:3: hook 'tidy': undefined variable "Mode"`},
+		},
+		{
+			name: "hook with a go package pattern",
+			files: map[string]string{manifestFileName: `{
+  "variables": [{"name": "Docker", "type": "bool", "default": false}],
+  "hooks": [{"run": ["go", "vet", "./..."]}]
+}`},
+		},
+		{
+			name:  "go.mod without a module line",
+			files: map[string]string{"go.mod.tmpl": "go 1.22\n"},
+			want:  []string{"go.mod.tmpl: no module line"},
+		},
+		{
+			name: "unused variable",
+			files: map[string]string{manifestFileName: `{
+  "variables": [
+    {"name": "Docker", "type": "bool", "default": false},
+    {"name": "Owner", "default": "acme"}
+  ]
+}`},
+			want: []string{`figo.json:4: variable "Owner" is never used`},
+		},
+		{
+			name: "variable used by another default",
+			files: map[string]string{
+				manifestFileName: `{
+  "variables": [
+    {"name": "Docker", "type": "bool", "default": false},
+    {"name": "Owner", "default": "acme"},
+    {"name": "Module", "default": "github.com/{{.Owner}}/{{.ProjectName}}"}
+  ]
+}`,
+				"go.mod.tmpl": "module {{.Module}}\n",
+			},
+		},
+		{
+			name:  "ignore pattern matching nothing",
+			files: map[string]string{ignoreFileName: "# authors only\ndocs/\n"},
+			want:  []string{".figoignore:2: pattern matches no files"},
+		},
+		{
+			name:  "ignored go.mod",
+			files: map[string]string{ignoreFileName: "go.mod*\n"},
+			want:  []string{".figoignore: go.mod.tmpl is ignored, so projects will not be Go modules"},
+		},
+		{
+			name:  "invalid ignore pattern",
+			files: map[string]string{ignoreFileName: "# authors only\n[\n"},
+			want:  []string{`.figoignore:2: invalid pattern "["`},
+		},
+		{
+			name:  "github directory",
+			files: map[string]string{".github/workflows/ci.yml": "on: push\n"},
+			want:  []string{".github: .github directories are never copied into projects"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFiles(t, dir, base)
+			writeTestFiles(t, dir, tt.files)
+
+			var got []string
+			for _, problem := range lintTemplate(dir) {
+				got = append(got, strings.TrimPrefix(problem.String(), dir+string(filepath.Separator)))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lintTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintTemplates(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFiles(t, repo, map[string]string{
+		"api/go.mod": "module example.com/api\n",
+		"cli/go.mod": "module example.com/cli\n",
+	})
+	if err := lintTemplates([]string{repo}); err != nil {
+		t.Errorf("lintTemplates() of clean templates error = %v", err)
+	}
+
+	writeTestFiles(t, repo, map[string]string{"cli/go.mod": "go 1.22\n"})
+	if err := lintTemplates([]string{repo}); err == nil {
+		t.Error("lintTemplates() passed a template with problems")
+	}
+
+	for _, dir := range []string{t.TempDir(), filepath.Join(repo, "api", "go.mod")} {
+		if err := lintTemplates([]string{dir}); err == nil {
+			t.Errorf("lintTemplates(%q) found templates", dir)
+		}
+	}
+}
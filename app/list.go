@@ -0,0 +1,247 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+)
+
+// listOptions filters and orders the templates shown by list-templates.
+type listOptions struct {
+	// Tags limits the list to templates that have all of these tags
+	Tags []string
+	// Source limits the list to templates from this repository
+	Source string
+	// Sort is "name" or "updated"; empty sorts by relevance when searching
+	Sort string
+	// Search fuzzily matches names and descriptions
+	Search string
+}
+
+// templateListing is one row of the template table.
+type templateListing struct {
+	ID          string
+	Description string
+	Tags        []string
+	Source      string
+	Updated     time.Time
+
+	entry *registryEntry
+	score int
+}
+
+// listTemplateDetails returns the installed templates matching opts, in the
+// order asked for.
+func listTemplateDetails(templates []string, registry *templateRegistry, opts listOptions) ([]templateListing, error) {
+	switch opts.Sort {
+	case "", "name", "updated":
+	default:
+		return nil, fmt.Errorf(color.RedString("Error: unknown sort order '%s', expected name or updated", opts.Sort))
+	}
+
+	var listings []templateListing
+	for _, id := range templates {
+		manifest, err := loadManifest(templatePath(id))
+		if err != nil {
+			fmt.Print(color.YellowString("Warning: template '%s': %v\n", id, err))
+			manifest = &templateManifest{}
+		}
+
+		listing := templateListing{
+			ID:          id,
+			Description: manifest.Description,
+			Tags:        manifest.Tags,
+			entry:       registry.Templates[id],
+		}
+		if entry := listing.entry; entry != nil {
+			listing.Source = formatSource(entry)
+			listing.Updated = entry.UpdatedAt
+		}
+
+		if !listing.matches(opts) {
+			continue
+		}
+		listings = append(listings, listing)
+	}
+
+	sort.SliceStable(listings, func(i, j int) bool {
+		a, b := listings[i], listings[j]
+		switch {
+		case opts.Sort == "updated" && !a.Updated.Equal(b.Updated):
+			return a.Updated.After(b.Updated)
+		case opts.Sort == "" && a.score != b.score:
+			return a.score > b.score
+		}
+		return a.ID < b.ID
+	})
+
+	return listings, nil
+}
+
+func (l *templateListing) matches(opts listOptions) bool {
+	if !hasTags(l.Tags, opts.Tags) {
+		return false
+	}
+
+	if opts.Source != "" && !l.fromSource(opts.Source) {
+		return false
+	}
+
+	if opts.Search != "" {
+		// Match the short name rather than the whole identifier, whose
+		// host and owner would match almost anything
+		segments := strings.Split(l.ID, "/")
+		shortName := strings.Join(segments[max(0, len(segments)-2):], "/")
+		l.score = max(fuzzyScore(opts.Search, shortName), fuzzyScore(opts.Search, l.Description))
+		if l.score < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hasTags reports whether have includes every tag in want, ignoring case.
+func hasTags(have []string, want []string) bool {
+	for _, tag := range want {
+		if !slices.ContainsFunc(have, func(t string) bool { return strings.EqualFold(t, tag) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// fromSource reports whether the template came from the repository source,
+// given either as a URL or as the host/owner/repo part of template names.
+func (l *templateListing) fromSource(source string) bool {
+	if strings.HasPrefix(l.ID, strings.Trim(source, "/")+"/") {
+		return true
+	}
+	if l.entry == nil {
+		return false
+	}
+
+	want, err := templateNamespace(source)
+	if err != nil {
+		return false
+	}
+	have, err := templateNamespace(l.entry.Source)
+	return err == nil && want == have
+}
+
+// fuzzyScore rates how well text matches query, ignoring case. Substrings
+// score highest, earlier ones more so; otherwise the characters of query
+// must appear in order, and the fewer gaps the better. It returns -1 for
+// no match.
+func fuzzyScore(query string, text string) int {
+	query, text = strings.ToLower(query), strings.ToLower(text)
+	if query == "" {
+		return 0
+	}
+
+	if i := strings.Index(text, query); i >= 0 {
+		return 1000 - i
+	}
+
+	score, gap := 500, 0
+	queryRunes := []rune(query)
+	for _, r := range text {
+		if len(queryRunes) == 0 {
+			break
+		}
+		if r == queryRunes[0] {
+			queryRunes = queryRunes[1:]
+			score -= gap
+			gap = 0
+		} else {
+			gap++
+		}
+	}
+	if len(queryRunes) > 0 {
+		return -1
+	}
+	return max(score, 0)
+}
+
+// formatSource describes where a template was installed from, as
+// <url>/<dir>@<ref>.
+func formatSource(entry *registryEntry) string {
+	source := entry.Source
+	if entry.Subdirectory != "" {
+		source += "/" + entry.Subdirectory
+	}
+	if entry.Ref != "" {
+		source += "@" + entry.Ref
+	} else if entry.Tag != "" {
+		source += "@" + entry.Tag
+	} else if entry.Revision != "" {
+		source += "@" + shortRevision(entry.Revision)
+	}
+	return source
+}
+
+// printTemplateTable prints templates as a table with their description,
+// tags, source and when they were last updated.
+func printTemplateTable(listings []templateListing) {
+	var rows [][]string
+	for _, listing := range listings {
+		source, updated := "-", "-"
+		if listing.entry != nil {
+			source = listing.Source
+			updated = listing.Updated.Local().Format("2006-01-02 15:04")
+		}
+		description, tags := "-", "-"
+		if listing.Description != "" {
+			description = truncate(listing.Description, maxDescriptionWidth)
+		}
+		if len(listing.Tags) > 0 {
+			tags = strings.Join(listing.Tags, ",")
+		}
+		rows = append(rows, []string{listing.ID, description, tags, source, updated})
+	}
+
+	printTable([]string{"NAME", "DESCRIPTION", "TAGS", "SOURCE", "UPDATED"}, rows)
+}
+
+// printTable prints rows in aligned columns under a highlighted header.
+func printTable(header []string, rows [][]string) {
+	var out bytes.Buffer
+	w := tabwriter.NewWriter(&out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+
+	headerLine, body, _ := strings.Cut(out.String(), "\n")
+	fmt.Println(color.YellowString(headerLine))
+	fmt.Print(body)
+}
+
+// truncate shortens text to at most width characters.
+func truncate(text string, width int) string {
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	return string([]rune(text)[:width-1]) + "…"
+}
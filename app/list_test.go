@@ -0,0 +1,121 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestListTemplateDetails(t *testing.T) {
+	useTestHome(t)
+
+	manifests := map[string]string{
+		"github.com/acme/templates/api":    `{"description": "HTTP API service", "tags": ["web", "http"]}`,
+		"github.com/acme/templates/worker": `{"description": "Queue worker", "tags": ["jobs"]}`,
+		"gitlab.com/other/kit/webapp":      `{"description": "Web application", "tags": ["web"]}`,
+		"local/cli":                        "",
+	}
+	var templates []string
+	for id, manifest := range manifests {
+		writeTestTemplate(t, id)
+		if manifest != "" {
+			writeTestFiles(t, templatePath(id), map[string]string{manifestFileName: manifest})
+		}
+		templates = append(templates, id)
+	}
+
+	now := time.Now()
+	registry := &templateRegistry{Templates: map[string]*registryEntry{
+		"github.com/acme/templates/api":    {Source: "https://github.com/acme/templates", Subdirectory: "api", UpdatedAt: now.Add(-time.Hour)},
+		"github.com/acme/templates/worker": {Source: "https://github.com/acme/templates", Subdirectory: "worker", UpdatedAt: now},
+		"gitlab.com/other/kit/webapp":      {Source: "git@gitlab.com:other/kit.git", Subdirectory: "webapp", UpdatedAt: now.Add(-2 * time.Hour)},
+	}}
+
+	tests := []struct {
+		name    string
+		opts    listOptions
+		want    []string
+		wantErr bool
+	}{
+		{name: "all", want: []string{"github.com/acme/templates/api", "github.com/acme/templates/worker", "gitlab.com/other/kit/webapp", "local/cli"}},
+		{name: "tag", opts: listOptions{Tags: []string{"WEB"}}, want: []string{"github.com/acme/templates/api", "gitlab.com/other/kit/webapp"}},
+		{name: "all tags", opts: listOptions{Tags: []string{"web", "http"}}, want: []string{"github.com/acme/templates/api"}},
+		{name: "source prefix", opts: listOptions{Source: "github.com/acme/templates"}, want: []string{"github.com/acme/templates/api", "github.com/acme/templates/worker"}},
+		{name: "source URL", opts: listOptions{Source: "https://gitlab.com/other/kit.git"}, want: []string{"gitlab.com/other/kit/webapp"}},
+		{name: "sort by update", opts: listOptions{Sort: "updated"}, want: []string{"github.com/acme/templates/worker", "github.com/acme/templates/api", "gitlab.com/other/kit/webapp", "local/cli"}},
+		{name: "search by relevance", opts: listOptions{Search: "w"}, want: []string{"gitlab.com/other/kit/webapp", "github.com/acme/templates/worker"}},
+		{name: "search by name", opts: listOptions{Search: "w", Sort: "name"}, want: []string{"github.com/acme/templates/worker", "gitlab.com/other/kit/webapp"}},
+		{name: "search description", opts: listOptions{Search: "queue"}, want: []string{"github.com/acme/templates/worker"}},
+		{name: "search without match", opts: listOptions{Search: "zzz"}},
+		{name: "unknown sort", opts: listOptions{Sort: "size"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listings, err := listTemplateDetails(templates, registry, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("listTemplateDetails() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var got []string
+			for _, listing := range listings {
+				got = append(got, listing.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("listTemplateDetails() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		query string
+		text  string
+		want  int
+	}{
+		{query: "", text: "api", want: 0},
+		{query: "api", text: "API service", want: 1000},
+		{query: "api", text: "http api", want: 995},
+		{query: "hts", text: "http service", want: 497},
+		{query: "xyz", text: "http service", want: -1},
+	}
+
+	for _, tt := range tests {
+		if got := fuzzyScore(tt.query, tt.text); got != tt.want {
+			t.Errorf("fuzzyScore(%q, %q) = %d, want %d", tt.query, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSource(t *testing.T) {
+	tests := []struct {
+		entry registryEntry
+		want  string
+	}{
+		{entry: registryEntry{Source: "https://github.com/acme/templates"}, want: "https://github.com/acme/templates"},
+		{entry: registryEntry{Source: "https://github.com/acme/templates", Subdirectory: "api", Ref: "main", Tag: "v1"}, want: "https://github.com/acme/templates/api@main"},
+		{entry: registryEntry{Source: "https://github.com/acme/templates", Tag: "v1", Revision: "0123456789abcdef0123"}, want: "https://github.com/acme/templates@v1"},
+		{entry: registryEntry{Source: "https://github.com/acme/templates", Revision: "0123456789abcdef0123"}, want: "https://github.com/acme/templates@0123456789ab"},
+	}
+
+	for _, tt := range tests {
+		if got := formatSource(&tt.entry); got != tt.want {
+			t.Errorf("formatSource(%+v) = %q, want %q", tt.entry, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// lockTemplates takes an exclusive advisory lock that guards writes to the
+// templates directory, waiting for other figo processes to release it. The
+// returned function releases the lock.
+func lockTemplates(ctx context.Context) (func(), error) {
+	return lockPath(ctx, getDefaultDirectory(templatesLockFileName), "updating templates")
+}
+
+// lockPath takes an exclusive advisory lock on the file at path, creating it
+// if needed. activity describes what the holder of the lock is doing and is
+// shown while waiting for it.
+func lockPath(ctx context.Context, path string, activity string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf(color.RedString("Error: creating lock directory: %v", err))
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf(color.RedString("Error: opening lock file: %v", err))
+	}
+
+	ticker := time.NewTicker(lockRetryInterval)
+	defer ticker.Stop()
+
+	for waiting := false; ; waiting = true {
+		locked, err := tryLockFile(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf(color.RedString("Error: locking %s: %v", path, err))
+		}
+		if locked {
+			break
+		}
+		if !waiting {
+			fmt.Println(color.YellowString("Waiting for another figo process to finish %s...", activity))
+		}
+
+		select {
+		case <-ctx.Done():
+			file.Close()
+			return nil, fmt.Errorf(color.RedString("Error: waiting for lock on %s: %v", path, ctx.Err()))
+		case <-ticker.C:
+		}
+	}
+
+	return func() {
+		unlockFile(file)
+		file.Close()
+	}, nil
+}
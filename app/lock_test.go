@@ -0,0 +1,60 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix || windows
+
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLockTemplates(t *testing.T) {
+	useTestHome(t)
+
+	unlock, err := lockTemplates(context.Background())
+	if err != nil {
+		t.Fatalf("lockTemplates() error = %v", err)
+	}
+
+	// A second holder waits until its context gives up
+	ctx, cancel := context.WithTimeout(context.Background(), 3*lockRetryInterval)
+	defer cancel()
+	if unlockAgain, err := lockTemplates(ctx); err == nil {
+		unlockAgain()
+		t.Fatal("lockTemplates() succeeded while the lock was held")
+	}
+
+	// and gets the lock once it is released
+	acquired := make(chan error, 1)
+	go func() {
+		unlockAgain, err := lockTemplates(context.Background())
+		if err == nil {
+			unlockAgain()
+		}
+		acquired <- err
+	}()
+	unlock()
+
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatalf("lockTemplates() after unlock error = %v", err)
+		}
+	case <-time.After(10 * lockRetryInterval):
+		t.Fatal("lockTemplates() did not get the lock after it was released")
+	}
+}
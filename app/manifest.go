@@ -0,0 +1,202 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"go/version"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// templateManifest is the optional figo.json file at the root of a template.
+// Templates with a manifest are rendered when a project is created: files
+// ending in .tmpl and path segments containing {{ }} are executed as Go
+// templates with the template's variables, then its hooks are run.
+type templateManifest struct {
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	// Go is the minimum Go version projects created from the template need
+	Go        string             `json:"go,omitempty"`
+	Variables []templateVariable `json:"variables,omitempty"`
+	Hooks     []templateHook     `json:"hooks,omitempty"`
+
+	// found is set when the template actually has a manifest file
+	found bool
+}
+
+// templateVariable is a value asked for when creating a project. Type is
+// one of "string" (the default), "bool" or "int"; string defaults may
+// themselves refer to other variables, as in "github.com/acme/{{.ProjectName}}".
+type templateVariable struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Default     any      `json:"default,omitempty"`
+	Choices     []string `json:"choices,omitempty"`
+}
+
+// templateHook is a command run in the new project once it has been created.
+type templateHook struct {
+	Name string   `json:"name,omitempty"`
+	Run  []string `json:"run"`
+}
+
+// loadManifest reads the manifest of the template in dir. Templates without a
+// manifest get an empty one.
+func loadManifest(dir string) (*templateManifest, error) {
+	manifest := &templateManifest{}
+
+	data, err := os.ReadFile(filepath.Join(dir, manifestFileName))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf(color.RedString("Error: reading template manifest: %v", err))
+	}
+
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf(color.RedString("Error: parsing template manifest %q: %v", filepath.Join(dir, manifestFileName), err))
+	}
+	if err := manifest.validate(); err != nil {
+		return nil, fmt.Errorf(color.RedString("Error: invalid template manifest %q: %v", filepath.Join(dir, manifestFileName), err))
+	}
+	manifest.found = true
+	return manifest, nil
+}
+
+func (m *templateManifest) validate() error {
+	if m.Go != "" && !version.IsValid("go"+m.Go) {
+		return fmt.Errorf("invalid Go version %q", m.Go)
+	}
+
+	for _, tag := range m.Tags {
+		if tag == "" || strings.ContainsAny(tag, ", \t\n") {
+			return fmt.Errorf("invalid tag %q", tag)
+		}
+	}
+
+	seen := map[string]bool{builtinProjectNameVariable: true}
+	for _, variable := range m.Variables {
+		if !token.IsIdentifier(variable.Name) {
+			return fmt.Errorf("variable name %q is not an identifier", variable.Name)
+		}
+		if seen[variable.Name] {
+			return fmt.Errorf("variable %q is declared more than once", variable.Name)
+		}
+		seen[variable.Name] = true
+
+		switch variable.Type {
+		case "", "string", "bool", "int":
+		default:
+			return fmt.Errorf("variable %q has unknown type %q", variable.Name, variable.Type)
+		}
+		if variable.Default != nil {
+			if _, err := variable.convert(variable.Default); err != nil {
+				return fmt.Errorf("variable %q has an invalid default: %v", variable.Name, err)
+			}
+		}
+	}
+
+	for i, hook := range m.Hooks {
+		if len(hook.Run) == 0 {
+			return fmt.Errorf("hook %d has no command", i+1)
+		}
+	}
+	return nil
+}
+
+func (v templateVariable) typeName() string {
+	if v.Type == "" {
+		return "string"
+	}
+	return v.Type
+}
+
+// parse converts a value given on the command line to the variable's type.
+func (v templateVariable) parse(value string) (any, error) {
+	switch v.typeName() {
+	case "bool":
+		return strconv.ParseBool(value)
+	case "int":
+		return strconv.Atoi(value)
+	}
+
+	if len(v.Choices) > 0 && !slices.Contains(v.Choices, value) {
+		return nil, fmt.Errorf("%q is not one of %s", value, strings.Join(v.Choices, ", "))
+	}
+	return value, nil
+}
+
+// convert converts a default, as decoded from the manifest, to the
+// variable's type. Strings are parsed like values given on the command line.
+func (v templateVariable) convert(value any) (any, error) {
+	switch value := value.(type) {
+	case string:
+		return v.parse(value)
+	case bool:
+		switch v.typeName() {
+		case "bool":
+			return value, nil
+		case "string":
+			return v.parse(strconv.FormatBool(value))
+		}
+	case float64:
+		switch v.typeName() {
+		case "int":
+			if i := int(value); float64(i) == value {
+				return i, nil
+			}
+		case "string":
+			return v.parse(strconv.FormatFloat(value, 'f', -1, 64))
+		}
+	}
+	return nil, fmt.Errorf("%v is not a valid %s", value, v.typeName())
+}
+
+// description returns a one-line summary of the hook for progress output.
+func (h templateHook) description() string {
+	if h.Name != "" {
+		return h.Name
+	}
+	return strings.Join(h.Run, " ")
+}
+
+// checkGoVersion warns when the installed Go toolchain is older than the
+// template asks for.
+func checkGoVersion(ctx context.Context, manifest *templateManifest) {
+	if manifest.Go == "" {
+		return
+	}
+
+	out, err := exec.CommandContext(ctx, "go", "env", "GOVERSION").Output()
+	if err != nil {
+		return
+	}
+
+	installed := strings.TrimSpace(string(out))
+	if version.Compare(installed, "go"+manifest.Go) < 0 {
+		fmt.Print(color.YellowString("Warning: the template needs Go %s or later, but %s is installed\n", manifest.Go, installed))
+	}
+}
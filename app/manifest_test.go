@@ -0,0 +1,65 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTemplateValuesDefaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		variable string
+		want     any
+		wantErr  bool
+	}{
+		{name: "large int", variable: `{"name": "Port", "type": "int", "default": 1000000}`, want: 1000000},
+		{name: "int from string", variable: `{"name": "Port", "type": "int", "default": "8080"}`, want: 8080},
+		{name: "fractional int", variable: `{"name": "Port", "type": "int", "default": 1.5}`, wantErr: true},
+		{name: "bool", variable: `{"name": "Docker", "type": "bool", "default": true}`, want: true},
+		{name: "bool from string", variable: `{"name": "Docker", "type": "bool", "default": "false"}`, want: false},
+		{name: "bool from number", variable: `{"name": "Docker", "type": "bool", "default": 1}`, wantErr: true},
+		{name: "string", variable: `{"name": "Module", "default": "example.com/{{.ProjectName}}"}`, want: "example.com/app"},
+		{name: "string from number", variable: `{"name": "Version", "default": 1000000}`, want: "1000000"},
+		{name: "choice", variable: `{"name": "DB", "default": "sqlite", "choices": ["postgres", "mysql"]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := &templateManifest{}
+			if err := json.Unmarshal([]byte(`{"variables": [`+tt.variable+`]}`), manifest); err != nil {
+				t.Fatal(err)
+			}
+
+			err := manifest.validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			values, err := templateValues(manifest, "app", nil, requireVariable)
+			if err != nil {
+				t.Fatalf("templateValues() error = %v", err)
+			}
+			name := manifest.Variables[0].Name
+			if values[name] != tt.want {
+				t.Errorf("%s = %#v, want %#v", name, values[name], tt.want)
+			}
+		})
+	}
+}
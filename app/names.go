@@ -0,0 +1,315 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Installed templates are identified by the repository they came from and
+// the directory they were found in, as in github.com/itpey/figo-templates/default.
+// A repository that is itself a template uses its own name as the directory.
+// Templates are stored under the same path in the templates directory.
+
+// templateNamespace returns the host/owner/repo part of the identifiers of
+// templates from the repository at repoURL. Repositories without a host,
+// such as local paths, are placed under localNamespace followed by their path.
+func templateNamespace(repoURL string) (string, error) {
+	host, repoPath := "", repoURL
+
+	if strings.Contains(repoURL, "://") {
+		parsedURL, err := url.Parse(repoURL)
+		if err != nil {
+			return "", fmt.Errorf(color.RedString("Error: failed to parse repository URL: %v", err))
+		}
+		host, repoPath = parsedURL.Hostname(), parsedURL.Path
+	} else if at, colon := strings.Index(repoURL, "@"), strings.Index(repoURL, ":"); colon > 1 && at < colon && !filepath.IsAbs(repoURL) {
+		// scp-like syntax: [user@]host:owner/repo
+		host, repoPath = repoURL[at+1:colon], repoURL[colon+1:]
+	}
+
+	if host == "" {
+		host = localNamespace
+		repoPath = strings.ReplaceAll(filepath.ToSlash(repoPath), ":", "")
+	}
+
+	repoPath = strings.Trim(strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git"), "/")
+	if repoPath == "" {
+		return "", fmt.Errorf(color.RedString("Error: unable to determine repository name from URL: %s", repoURL))
+	}
+	return strings.ToLower(host) + "/" + repoPath, nil
+}
+
+// templatePath returns the directory an installed template is stored in.
+func templatePath(id string) string {
+	return filepath.Join(templatesDirectory, filepath.FromSlash(id))
+}
+
+// legacyTemplateName returns the name a template had before identifiers were
+// namespaced: <repo>_<dir>, or just <repo> for a repository template.
+func legacyTemplateName(id string) string {
+	segments := strings.Split(id, "/")
+	if len(segments) < 2 {
+		return id
+	}
+
+	repo, dir := segments[len(segments)-2], segments[len(segments)-1]
+	if repo == dir {
+		return repo
+	}
+	return repo + "_" + dir
+}
+
+// resolveTemplate finds the installed template that name refers to. Besides
+// full identifiers it accepts any unambiguous trailing part of one, such as
+// "default" or "figo-templates/default", and pre-namespace names.
+func resolveTemplate(installed []string, name string) (string, error) {
+	return resolveTemplateWith(installed, name, false)
+}
+
+// resolveTemplateByPriority is resolveTemplate for picking a template to use
+// rather than to change: when name is ambiguous, the template from the
+// highest priority configured source wins.
+func resolveTemplateByPriority(installed []string, name string) (string, error) {
+	return resolveTemplateWith(installed, name, true)
+}
+
+func resolveTemplateWith(installed []string, name string, byPriority bool) (string, error) {
+	name = strings.Trim(filepath.ToSlash(name), "/")
+
+	if slices.Contains(installed, name) {
+		return name, nil
+	}
+	if id, ok, err := resolveAlias(installed, name); ok || err != nil {
+		return id, err
+	}
+
+	var matches []string
+	for _, id := range installed {
+		if strings.HasSuffix(id, "/"+name) || legacyTemplateName(id) == name {
+			matches = append(matches, id)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		if suggestions := suggestTemplates(installed, name); len(suggestions) > 0 {
+			return "", fmt.Errorf(color.RedString("Error: template '%s' not found, did you mean:\n  %s", name, strings.Join(suggestions, "\n  ")))
+		}
+		return "", fmt.Errorf(color.RedString("Error: template '%s' not found", name))
+	case 1:
+		return matches[0], nil
+	default:
+		if byPriority {
+			sort.SliceStable(matches, func(i, j int) bool {
+				return config.sourcePriority(matches[i]) < config.sourcePriority(matches[j])
+			})
+			if best := config.sourcePriority(matches[0]); best < config.sourcePriority(matches[1]) {
+				return matches[0], nil
+			}
+		}
+		sort.Strings(matches)
+		return "", fmt.Errorf(color.RedString("Error: template '%s' is ambiguous, it could be any of:\n  %s", name, strings.Join(matches, "\n  ")))
+	}
+}
+
+// suggestTemplates returns the installed templates whose names are closest
+// to name, for when it matches none of them.
+func suggestTemplates(installed []string, name string) []string {
+	name = strings.ToLower(name)
+	maxDistance := max(2, len(name)/3)
+
+	type suggestion struct {
+		id       string
+		distance int
+	}
+	var suggestions []suggestion
+	for _, id := range installed {
+		segments := strings.Split(strings.ToLower(id), "/")
+		leaf := segments[len(segments)-1]
+
+		distance := min(editDistance(name, leaf), editDistance(name, strings.ToLower(legacyTemplateName(id))))
+		for i := range segments {
+			distance = min(distance, editDistance(name, strings.Join(segments[i:], "/")))
+		}
+		if strings.Contains(leaf, name) || strings.Contains(name, leaf) {
+			distance = min(distance, 1)
+		}
+
+		if distance <= maxDistance {
+			suggestions = append(suggestions, suggestion{id, distance})
+		}
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].distance != suggestions[j].distance {
+			return suggestions[i].distance < suggestions[j].distance
+		}
+		return suggestions[i].id < suggestions[j].id
+	})
+
+	var ids []string
+	for _, s := range suggestions[:min(len(suggestions), maxSuggestions)] {
+		ids = append(ids, s.id)
+	}
+	return ids
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	previous := make([]int, len(br)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := range ar {
+		current := make([]int, len(br)+1)
+		current[0] = i + 1
+		for j := range br {
+			cost := 1
+			if ar[i] == br[j] {
+				cost = 0
+			}
+			current[j+1] = min(previous[j+1]+1, current[j]+1, previous[j]+cost)
+		}
+		previous = current
+	}
+	return previous[len(br)]
+}
+
+// migrateTemplateLayout moves templates installed under flat names, like
+// figo-templates_default, to their namespaced identifiers. It only runs
+// once, when the registry is older than the namespaced layout; templates
+// with no recorded source, including everything installed before the
+// registry existed, move under the legacy namespace.
+func migrateTemplateLayout(ctx context.Context) error {
+	registry, err := loadRegistry()
+	if err != nil || registry.Version >= namespacedRegistryVersion {
+		return err
+	}
+
+	unlock, err := lockTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Another process may have migrated while we waited for the lock
+	registry, err = loadRegistry()
+	if err != nil || registry.Version >= namespacedRegistryVersion {
+		return err
+	}
+
+	installed, err := installedTemplates()
+	if err != nil {
+		return err
+	}
+
+	names := map[string]bool{}
+	for name := range registry.Templates {
+		names[name] = true
+	}
+	for _, name := range installed {
+		if !strings.Contains(name, "/") {
+			names[name] = true
+		}
+	}
+
+	migrated := map[string]*registryEntry{}
+	for name := range names {
+		entry, registered := registry.Templates[name]
+		keep := func() {
+			if registered {
+				migrated[name] = entry
+			}
+		}
+
+		id, err := migratedTemplateID(name, entry)
+		if err != nil || id == name {
+			keep()
+			continue
+		}
+		if !exists(templatePath(name)) {
+			keep()
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(templatePath(id)), 0755); err != nil {
+			return fmt.Errorf(color.RedString("Error: creating templates directory: %v", err))
+		}
+		if exists(templatePath(id)) {
+			fmt.Print(color.YellowString("Warning: could not move template '%s' to '%s': already exists\n", name, id))
+			keep()
+			continue
+		}
+		if err := os.Rename(templatePath(name), templatePath(id)); err != nil {
+			fmt.Print(color.YellowString("Warning: could not move template '%s' to '%s': %v\n", name, id, err))
+			keep()
+			continue
+		}
+
+		fmt.Print(color.YellowString("Moved template '%s' to '%s'\n", name, id))
+		if registered {
+			migrated[id] = entry
+		}
+	}
+
+	registry.Templates = migrated
+	registry.Version = registryVersion
+	return registry.save()
+}
+
+func migratedTemplateID(name string, entry *registryEntry) (string, error) {
+	if entry == nil || entry.Source == "" {
+		return legacyNamespace + "/" + name, nil
+	}
+
+	namespace, err := templateNamespace(entry.Source)
+	if err != nil {
+		return "", err
+	}
+
+	leaf := entry.Subdirectory
+	if leaf == "" {
+		leaf = path.Base(namespace)
+	}
+
+	// Templates that were added under another name keep that name
+	if id := namespace + "/" + leaf; legacyTemplateName(id) == name {
+		return id, nil
+	}
+	return namespace + "/" + name, nil
+}
+
+// removeEmptyParents removes the namespace directories above a deleted
+// template that no longer hold any templates.
+func removeEmptyParents(dir string) {
+	for dir = filepath.Dir(dir); dir != templatesDirectory && strings.HasPrefix(dir, templatesDirectory); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
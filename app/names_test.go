@@ -0,0 +1,261 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestTemplateNamespace(t *testing.T) {
+	tests := []struct {
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{url: "https://github.com/itpey/figo-templates", want: "github.com/itpey/figo-templates"},
+		{url: "https://GitHub.com/itpey/figo-templates.git/", want: "github.com/itpey/figo-templates"},
+		{url: "ssh://git@gitlab.com:2222/group/sub/repo.git", want: "gitlab.com/group/sub/repo"},
+		{url: "git@github.com:itpey/figo-templates.git", want: "github.com/itpey/figo-templates"},
+		{url: "/srv/templates", want: "local/srv/templates"},
+		{url: "https://github.com/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := templateNamespace(tt.url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("templateNamespace(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("templateNamespace(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestResolveTemplate(t *testing.T) {
+	installed := []string{
+		"github.com/itpey/figo-templates/default",
+		"github.com/itpey/figo-templates/figo-templates",
+		"github.com/acme/templates/api",
+		"gitlab.com/acme/templates/api",
+	}
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "github.com/acme/templates/api", want: "github.com/acme/templates/api"},
+		{name: "default", want: "github.com/itpey/figo-templates/default"},
+		{name: "figo-templates/default", want: "github.com/itpey/figo-templates/default"},
+		{name: "figo-templates_default", want: "github.com/itpey/figo-templates/default"},
+		{name: "figo-templates", want: "github.com/itpey/figo-templates/figo-templates"},
+		{name: "gitlab.com/acme/templates/api/", want: "gitlab.com/acme/templates/api"},
+		{name: "api", wantErr: true},
+		{name: "templates_api", wantErr: true},
+		{name: "missing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := resolveTemplate(installed, tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("resolveTemplate(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("resolveTemplate(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestTemplates(t *testing.T) {
+	installed := []string{
+		"github.com/itpey/figo-templates/default",
+		"github.com/acme/templates/api",
+		"github.com/acme/templates/apigateway",
+		"github.com/acme/templates/worker",
+	}
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{name: "defualt", want: []string{"github.com/itpey/figo-templates/default"}},
+		{name: "apis", want: []string{"github.com/acme/templates/api"}},
+		{name: "gateway", want: []string{"github.com/acme/templates/apigateway"}},
+		{name: "templates/wroker", want: []string{"github.com/acme/templates/worker"}},
+		{name: "database"},
+	}
+
+	for _, tt := range tests {
+		if got := suggestTemplates(installed, tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("suggestTemplates(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveTemplateByPriority(t *testing.T) {
+	defer func(c *figoConfig) { config = c }(config)
+	config = &figoConfig{Sources: []sourceConfig{
+		{URL: "https://github.com/acme/go-templates"},
+		{URL: "https://github.com/itpey/figo-templates"},
+	}}
+
+	installed := []string{
+		"github.com/itpey/figo-templates/api",
+		"github.com/acme/go-templates/api",
+		"github.com/itpey/figo-templates/default",
+		"gitlab.com/other/kit/worker",
+		"gitlab.com/more/kit/worker",
+	}
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "api", want: "github.com/acme/go-templates/api"},
+		{name: "figo-templates/api", want: "github.com/itpey/figo-templates/api"},
+		{name: "default", want: "github.com/itpey/figo-templates/default"},
+		// Neither source is configured, so neither wins
+		{name: "worker", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := resolveTemplateByPriority(installed, tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("resolveTemplateByPriority(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("resolveTemplateByPriority(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Changing a template needs its exact name
+	if _, err := resolveTemplate(installed, "api"); err == nil {
+		t.Error("resolveTemplate() picked an ambiguous template by priority")
+	}
+}
+
+func TestMigrateTemplateLayout(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed []string
+		registry  *templateRegistry
+		want      []string
+		wantIDs   []string
+	}{
+		{
+			name:      "no registry",
+			installed: []string{"figo-templates_default", "api"},
+			want:      []string{"legacy/api", "legacy/figo-templates_default"},
+		},
+		{
+			name:      "registered sources",
+			installed: []string{"templates_api", "mine"},
+			registry: &templateRegistry{Version: 1, Templates: map[string]*registryEntry{
+				"templates_api": {Source: "https://github.com/acme/templates", Subdirectory: "api"},
+				"mine":          {Description: "no source"},
+			}},
+			want:    []string{"github.com/acme/templates/api", "legacy/mine"},
+			wantIDs: []string{"github.com/acme/templates/api", "legacy/mine"},
+		},
+		{
+			name:      "already namespaced",
+			installed: []string{"figo-templates_default"},
+			registry:  &templateRegistry{Version: registryVersion, Templates: map[string]*registryEntry{}},
+			want:      []string{"figo-templates_default"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestHome(t)
+			for _, id := range tt.installed {
+				writeTestTemplate(t, id)
+			}
+			if tt.registry != nil {
+				if err := tt.registry.save(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := migrateTemplateLayout(context.Background()); err != nil {
+				t.Fatalf("migrateTemplateLayout() error = %v", err)
+			}
+
+			installed, err := installedTemplates()
+			if err != nil {
+				t.Fatal(err)
+			}
+			slices.Sort(installed)
+			if !slices.Equal(installed, tt.want) {
+				t.Errorf("installed templates = %v, want %v", installed, tt.want)
+			}
+
+			registry, err := loadRegistry()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if registry.Version != registryVersion {
+				t.Errorf("registry version = %d, want %d", registry.Version, registryVersion)
+			}
+			var ids []string
+			for id := range registry.Templates {
+				ids = append(ids, id)
+			}
+			slices.Sort(ids)
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("registry templates = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestMigrateTemplateLayoutNothingInstalled(t *testing.T) {
+	useTestHome(t)
+
+	if err := migrateTemplateLayout(context.Background()); err != nil {
+		t.Fatalf("migrateTemplateLayout() error = %v", err)
+	}
+
+	for _, path := range []string{templatesDirectory, registryPath(), getDefaultDirectory(templatesLockFileName)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was created", path)
+		}
+	}
+
+	// The first template installed afterwards is already in the new layout
+	registry, err := loadRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestTemplate(t, "local/api")
+	registry.Templates["local/api"] = &registryEntry{}
+	if err := registry.save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateTemplateLayout(context.Background()); err != nil {
+		t.Fatalf("migrateTemplateLayout() error = %v", err)
+	}
+	if !templateExists("local/api") {
+		t.Error("migrateTemplateLayout() moved a template installed in the new layout")
+	}
+}
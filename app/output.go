@@ -0,0 +1,213 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+)
+
+// tailBuffer keeps only the last few lines written to it, so that the output
+// of a long running command can be reported on failure without holding all of it.
+type tailBuffer struct {
+	mu      sync.Mutex
+	max     int
+	lines   []string
+	partial []byte
+}
+
+func newTailBuffer(max int) *tailBuffer {
+	return &tailBuffer{max: max}
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.partial = append(t.partial, p...)
+	for {
+		i := bytes.IndexByte(t.partial, '\n')
+		if i < 0 {
+			break
+		}
+		t.lines = append(t.lines, string(t.partial[:i]))
+		t.partial = t.partial[i+1:]
+	}
+	t.partial = trimPartialLine(t.partial)
+	if len(t.lines) > t.max {
+		t.lines = t.lines[len(t.lines)-t.max:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lines := t.lines
+	if len(t.partial) > 0 {
+		lines = append(lines[:len(lines):len(lines)], string(t.partial))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// prefixWriter copies complete lines to w, each one prefixed with the step name.
+type prefixWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	prefix  string
+	partial []byte
+}
+
+func newPrefixWriter(w io.Writer, step string) *prefixWriter {
+	return &prefixWriter{w: w, prefix: color.CyanString("[%s] ", step)}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.partial = append(p.partial, b...)
+	for {
+		i := bytes.IndexByte(p.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if _, err := fmt.Fprintf(p.w, "%s%s\n", p.prefix, p.partial[:i]); err != nil {
+			return 0, err
+		}
+		p.partial = p.partial[i+1:]
+	}
+
+	// Write out overly long lines in pieces rather than holding them
+	if len(p.partial) >= maxPartialLineSize {
+		if _, err := fmt.Fprintf(p.w, "%s%s\n", p.prefix, p.partial); err != nil {
+			return 0, err
+		}
+		p.partial = nil
+	}
+	return len(b), nil
+}
+
+// Flush writes out a trailing line that was not terminated by a newline.
+func (p *prefixWriter) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.partial) > 0 {
+		fmt.Fprintf(p.w, "%s%s\n", p.prefix, p.partial)
+		p.partial = nil
+	}
+}
+
+// spinner animates a single status line while a step runs, showing the most
+// recent line of output the step produced.
+type spinner struct {
+	mu          sync.Mutex
+	description string
+	last        string
+	partial     []byte
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+func startSpinner(description string) *spinner {
+	s := &spinner{
+		description: description,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *spinner) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.partial = append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if line := strings.TrimSpace(string(s.partial[:i])); line != "" {
+			s.last = line
+		}
+		s.partial = s.partial[i+1:]
+	}
+	s.partial = trimPartialLine(s.partial)
+	return len(p), nil
+}
+
+func (s *spinner) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for frame := 0; ; frame++ {
+		s.mu.Lock()
+		last := s.last
+		s.mu.Unlock()
+
+		elapsed := time.Since(start).Truncate(time.Second)
+		fmt.Printf("\r\033[K%s Running %s (%s) %s",
+			color.CyanString(spinnerFrames[frame%len(spinnerFrames)]), s.description, elapsed, color.HiBlackString(truncate(last, spinnerLineWidth)))
+
+		select {
+		case <-s.stop:
+			fmt.Print("\r\033[K")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop halts the animation and clears the status line.
+func (s *spinner) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+// trimPartialLine keeps the end of a line that has not been terminated yet
+// once it grows beyond maxPartialLineSize, starting at a whole character.
+func trimPartialLine(partial []byte) []byte {
+	if len(partial) <= maxPartialLineSize {
+		return partial
+	}
+	partial = partial[len(partial)-maxPartialLineSize:]
+	for len(partial) > 0 && !utf8.RuneStart(partial[0]) {
+		partial = partial[1:]
+	}
+	return slices.Clone(partial)
+}
+
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  string
+	}{
+		{text: "short", width: 10, want: "short"},
+		{text: "exactly", width: 7, want: "exactly"},
+		{text: "truncated", width: 5, want: "trun…"},
+		{text: "héllo wörld", width: 7, want: "héllo …"},
+		{text: "日本語のテキスト", width: 3, want: "日本…"},
+	}
+
+	for _, tt := range tests {
+		if got := truncate(tt.text, tt.width); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestPartialLinesAreBounded(t *testing.T) {
+	long := strings.Repeat("é", maxPartialLineSize)
+
+	var out bytes.Buffer
+	prefix := newPrefixWriter(&out, "step")
+	prefix.Write([]byte(long))
+	if len(prefix.partial) >= maxPartialLineSize {
+		t.Errorf("prefixWriter holds %d bytes of an unfinished line", len(prefix.partial))
+	}
+	if !strings.Contains(out.String(), long) {
+		t.Errorf("prefixWriter did not write out the long line")
+	}
+
+	tail := newTailBuffer(commandOutputTailLines)
+	tail.Write([]byte("x" + long))
+	if len(tail.partial) > maxPartialLineSize || !utf8.Valid(tail.partial) {
+		t.Errorf("tailBuffer holds %d bytes of an unfinished line, valid UTF-8: %v", len(tail.partial), utf8.Valid(tail.partial))
+	}
+
+	s := &spinner{}
+	s.Write([]byte("x" + long))
+	s.Write([]byte("\nlast line\n"))
+	if len(s.partial) > maxPartialLineSize || s.last != "last line" {
+		t.Errorf("spinner holds %d bytes of an unfinished line, last line %q", len(s.partial), s.last)
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// registryEntry records where an installed template came from.
+type registryEntry struct {
+	Source       string    `json:"source"`
+	Subdirectory string    `json:"subdirectory,omitempty"`
+	Ref          string    `json:"ref,omitempty"`
+	Revision     string    `json:"revision,omitempty"`
+	Tag          string    `json:"tag,omitempty"`
+	InstalledAt  time.Time `json:"installed_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Hash         string    `json:"hash"`
+	Description  string    `json:"description,omitempty"`
+	// SignedBy names the trusted key the template was signed with
+	SignedBy string `json:"signed_by,omitempty"`
+	// Files holds the sha256 of every file of the template as installed,
+	// keyed by its path in the template. Neither files nor hash are recorded
+	// for templates without a source, which are there to be edited
+	Files map[string]string `json:"files,omitempty"`
+}
+
+// templateRegistry is the registry.json file in the figo data directory that
+// keeps track of every installed template. Callers that modify it must hold
+// the templates lock.
+type templateRegistry struct {
+	Version   int                       `json:"version"`
+	Templates map[string]*registryEntry `json:"templates"`
+	// Aliases maps names of the user's choosing to template identifiers
+	Aliases map[string]string `json:"aliases,omitempty"`
+}
+
+// templateSource describes the repository revision templates are installed
+// from. Ref is the branch, tag or commit that was asked for, if any, and
+// Revision the commit it resolved to.
+type templateSource struct {
+	URL      string
+	Ref      string
+	Revision string
+	Tag      string
+}
+
+func registryPath() string {
+	return getDefaultDirectory(registryFileName)
+}
+
+func loadRegistry() (*templateRegistry, error) {
+	registry := &templateRegistry{Version: registryVersion, Templates: map[string]*registryEntry{}}
+
+	data, err := os.ReadFile(registryPath())
+	if os.IsNotExist(err) {
+		// Installs from before the registry existed still use the flat layout
+		if exists(templatesDirectory) {
+			registry.Version = 0
+		}
+		return registry, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf(color.RedString("Error: reading template registry: %v", err))
+	}
+
+	if err := json.Unmarshal(data, registry); err != nil {
+		return nil, fmt.Errorf(color.RedString("Error: parsing template registry %q: %v", registryPath(), err))
+	}
+	if registry.Templates == nil {
+		registry.Templates = map[string]*registryEntry{}
+	}
+	return registry, nil
+}
+
+// save writes the registry atomically so that a crash never leaves it truncated.
+func (r *templateRegistry) save() error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: encoding template registry: %v", err))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(registryPath()), 0755); err != nil {
+		return fmt.Errorf(color.RedString("Error: creating figo directory: %v", err))
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(registryPath()), registryFileName+".*")
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: writing template registry: %v", err))
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf(color.RedString("Error: writing template registry: %v", err))
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf(color.RedString("Error: writing template registry: %v", err))
+	}
+	if err := os.Rename(tmp.Name(), registryPath()); err != nil {
+		return fmt.Errorf(color.RedString("Error: writing template registry: %v", err))
+	}
+	return nil
+}
+
+// record stores the metadata of a template that was just installed into the
+// templates directory.
+func (r *templateRegistry) record(name string, source templateSource, subdirectory string) error {
+	files, err := templateFiles(templatePath(name))
+	if err != nil {
+		return err
+	}
+
+	manifest, err := loadManifest(templatePath(name))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	entry := &registryEntry{
+		Source:       source.URL,
+		Subdirectory: subdirectory,
+		Ref:          source.Ref,
+		Revision:     source.Revision,
+		Tag:          source.Tag,
+		InstalledAt:  now,
+		UpdatedAt:    now,
+		Description:  manifest.Description,
+	}
+	if source.URL != "" {
+		entry.Hash = digestFiles(files)
+		entry.Files = files
+	}
+	if previous, ok := r.Templates[name]; ok {
+		entry.InstalledAt = previous.InstalledAt
+	}
+
+	r.Templates[name] = entry
+	return nil
+}
+
+// editable reports whether the template has no source and no recorded files,
+// like copies and captured templates, so that changes to it are its own.
+func (e *registryEntry) editable() bool {
+	return e.Source == "" && e.Hash == ""
+}
+
+// prune drops entries whose template directory no longer exists and reports
+// whether anything changed.
+func (r *templateRegistry) prune() bool {
+	changed := false
+	for name := range r.Templates {
+		if _, err := os.Stat(templatePath(name)); os.IsNotExist(err) {
+			delete(r.Templates, name)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// syncRegistry loads the registry, dropping entries for templates that were
+// removed from the templates directory by hand.
+func syncRegistry(ctx context.Context) (*templateRegistry, error) {
+	unlock, err := lockTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return nil, err
+	}
+	if registry.prune() {
+		if err := registry.save(); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// hashTemplate computes a digest over the relative paths and contents of
+// every file that would be copied from the template in dir.
+func hashTemplate(dir string) (string, error) {
+	files, err := templateFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	return digestFiles(files), nil
+}
+
+// templateFiles returns the hash of every file that would be copied from the
+// template in dir, keyed by its slash-separated path relative to dir.
+func templateFiles(dir string) (map[string]string, error) {
+	files := map[string]string{}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && shouldSkipDir(info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		// The signature is made over the hash, so it can't be part of it
+		if shouldSkipFile(info.Name()) || relPath == signatureFileName {
+			return nil
+		}
+
+		fileHash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(relPath)] = fileHash
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf(color.RedString("Error: hashing template: %v", err))
+	}
+	return files, nil
+}
+
+// digestFiles combines file hashes into the digest of a whole template.
+func digestFiles(files map[string]string) string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	// The digest covers one "<file hash>  <path>" line per file
+	digest := sha256.New()
+	for _, path := range paths {
+		fmt.Fprintf(digest, "%s  %s\n", files[path], path)
+	}
+	return "sha256:" + hex.EncodeToString(digest.Sum(nil))
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf(color.RedString("Error: hashing template: %v", err))
+	}
+	defer file.Close()
+
+	digest := sha256.New()
+	if _, err := io.Copy(digest, file); err != nil {
+		return "", fmt.Errorf(color.RedString("Error: hashing %q: %v", path, err))
+	}
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRegistryRecord(t *testing.T) {
+	useTestHome(t)
+
+	writeTestTemplate(t, "acme_api")
+	writeTestFiles(t, filepath.Join(templatesDirectory, "acme_api"), map[string]string{
+		manifestFileName: `{"description": "HTTP API"}`,
+	})
+
+	registry, err := loadRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := templateSource{URL: "https://github.com/acme/templates", Revision: "abc123", Tag: "v1.0.0"}
+	if err := registry.record("acme_api", source, "api"); err != nil {
+		t.Fatalf("record() error = %v", err)
+	}
+	if err := registry.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err = loadRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := *registry.Templates["acme_api"]
+	if first.Source != source.URL || first.Revision != source.Revision || first.Tag != source.Tag || first.Subdirectory != "api" {
+		t.Errorf("recorded entry = %+v, want source %+v in api", first, source)
+	}
+	if first.Description != "HTTP API" {
+		t.Errorf("recorded description = %q, want %q", first.Description, "HTTP API")
+	}
+	if first.Hash == "" || first.InstalledAt.IsZero() || !first.UpdatedAt.Equal(first.InstalledAt) {
+		t.Errorf("recorded entry = %+v, want a hash and matching timestamps", first)
+	}
+
+	// Recording an update keeps the install time and tracks the new content
+	writeTestFiles(t, filepath.Join(templatesDirectory, "acme_api"), map[string]string{"main.go": "package main\n"})
+	if err := registry.record("acme_api", templateSource{URL: source.URL, Revision: "def456"}, "api"); err != nil {
+		t.Fatalf("record() error = %v", err)
+	}
+	second := registry.Templates["acme_api"]
+	if !second.InstalledAt.Equal(first.InstalledAt) || second.UpdatedAt.Before(first.UpdatedAt) {
+		t.Errorf("updated entry times = %v, %v; want install time %v kept", second.InstalledAt, second.UpdatedAt, first.InstalledAt)
+	}
+	if second.Hash == first.Hash || second.Revision != "def456" || second.Tag != "" {
+		t.Errorf("updated entry = %+v, want a new hash and revision def456", second)
+	}
+}
+
+func TestRegistryPrune(t *testing.T) {
+	useTestHome(t)
+
+	writeTestTemplate(t, "kept")
+	registry := &templateRegistry{Version: registryVersion, Templates: map[string]*registryEntry{
+		"kept":    {Source: "a"},
+		"removed": {Source: "b"},
+	}}
+
+	if !registry.prune() {
+		t.Error("prune() = false, want true")
+	}
+	var names []string
+	for name := range registry.Templates {
+		names = append(names, name)
+	}
+	if !slices.Equal(names, []string{"kept"}) {
+		t.Errorf("templates after prune = %v, want [kept]", names)
+	}
+	if registry.prune() {
+		t.Error("second prune() = true, want false")
+	}
+}
+
+func TestHashTemplate(t *testing.T) {
+	base := map[string]string{"go.mod": "module a\n", "main.go": "package main\n"}
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		removed []string
+		same    bool
+	}{
+		{name: "same files", files: base, same: true},
+		{name: "ignored directory", files: map[string]string{".git/HEAD": "ref"}, same: true},
+		{name: "changed content", files: map[string]string{"main.go": "package other\n"}},
+		{name: "added file", files: map[string]string{"README.md": "docs\n"}},
+		{name: "moved file", files: map[string]string{"cmd/main.go": "package main\n"}, removed: []string{"main.go"}},
+	}
+
+	want := hashTestFiles(t, base, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hashTestFiles(t, base, tt.files, tt.removed...); (got == want) != tt.same {
+				t.Errorf("hashTemplate() = %s, base hash %s, want same %v", got, want, tt.same)
+			}
+		})
+	}
+}
+
+func hashTestFiles(t *testing.T, base map[string]string, extra map[string]string, removed ...string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeTestFiles(t, dir, base)
+	writeTestFiles(t, dir, extra)
+	for _, name := range removed {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hash, err := hashTemplate(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
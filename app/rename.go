@@ -0,0 +1,145 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// renameTemplate gives an installed template a new name, keeping its
+// source so that it can still be updated, and the aliases that stand for it.
+func renameTemplate(ctx context.Context, name string, newName string) error {
+	unlock, err := lockTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	id, newID, err := resolveTargetName(name, newName)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(templatePath(newID)), 0755); err != nil {
+		return fmt.Errorf(color.RedString("Error: creating templates directory: %v", err))
+	}
+	if err := os.Rename(templatePath(id), templatePath(newID)); err != nil {
+		return fmt.Errorf(color.RedString("Error: renaming template '%s': %v", id, err))
+	}
+	removeEmptyParents(templatePath(id))
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	if entry, ok := registry.Templates[id]; ok {
+		registry.Templates[newID] = entry
+		delete(registry.Templates, id)
+	}
+	for alias, target := range registry.Aliases {
+		if target == id {
+			registry.Aliases[alias] = newID
+		}
+	}
+	if err := registry.save(); err != nil {
+		return err
+	}
+
+	fmt.Print(color.GreenString("Template '%s' renamed to '%s'\n", id, newID))
+	return nil
+}
+
+// copyInstalledTemplate makes a copy of an installed template to customize.
+// A new name without a slash puts the copy in the local namespace. The copy
+// has no source, so updates never overwrite changes made to it.
+func copyInstalledTemplate(ctx context.Context, name string, newName string) error {
+	unlock, err := lockTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if !strings.Contains(filepath.ToSlash(newName), "/") {
+		if !isValidTemplateName(newName) {
+			return fmt.Errorf(color.RedString("Error: invalid template name: %s", newName))
+		}
+		newName = localNamespace + "/" + newName
+	}
+	id, newID, err := resolveTargetName(name, newName)
+	if err != nil {
+		return err
+	}
+
+	if err := replaceTemplate(ctx, templatePath(id), newID); err != nil {
+		return err
+	}
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	if err := registry.record(newID, templateSource{}, ""); err != nil {
+		return err
+	}
+	if err := registry.save(); err != nil {
+		return err
+	}
+
+	fmt.Print(color.GreenString("Template '%s' copied to '%s'\n", id, newID))
+	fmt.Printf("Customize it in %s\n", templatePath(newID))
+	return nil
+}
+
+// resolveTargetName resolves the installed template name refers to and the
+// identifier newName gives it: a full identifier when it contains a slash,
+// or otherwise a new name in the same namespace. The new identifier must
+// not be taken, nor overlap another template.
+func resolveTargetName(name string, newName string) (string, string, error) {
+	installed, err := installedTemplates()
+	if err != nil {
+		return "", "", err
+	}
+	id, err := resolveTemplate(installed, name)
+	if err != nil {
+		return "", "", err
+	}
+
+	newID := strings.Trim(filepath.ToSlash(newName), "/")
+	if !strings.Contains(newID, "/") {
+		if !isValidTemplateName(newID) {
+			return "", "", fmt.Errorf(color.RedString("Error: invalid template name: %s", newName))
+		}
+		newID = renameTemplateID(id, newID)
+	}
+	if !isValidTemplateID(newID) {
+		return "", "", fmt.Errorf(color.RedString("Error: invalid template name: %s", newName))
+	}
+
+	for _, other := range installed {
+		switch {
+		case other == newID:
+			return "", "", fmt.Errorf(color.RedString("Error: template '%s' already exists", newID))
+		case strings.HasPrefix(newID, other+"/"), strings.HasPrefix(other, newID+"/"):
+			return "", "", fmt.Errorf(color.RedString("Error: '%s' would overlap template '%s'", newID, other))
+		}
+	}
+	return id, newID, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyInstalledTemplate(t *testing.T) {
+	useTestHome(t)
+	ctx := context.Background()
+	recordTestTemplate(t, "github.com/acme/templates/api", map[string]string{
+		manifestFileName: `{"description": "HTTP API"}`,
+		"go.mod":         "module example\n\ngo 1.22\n",
+		"main.go":        "package main\n\nfunc main() {}\n",
+	})
+
+	if err := copyInstalledTemplate(ctx, "api", "my-api"); err != nil {
+		t.Fatalf("copyInstalledTemplate() error = %v", err)
+	}
+	registry, err := loadRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := registry.Templates["local/my-api"]
+	if !ok {
+		t.Fatalf("copy not recorded as local/my-api, registry = %v", registry.Templates)
+	}
+	if entry.Source != "" || entry.Hash != "" || entry.Files != nil {
+		t.Errorf("copy recorded as %+v, want no source, hash or files", entry)
+	}
+	if err := copyInstalledTemplate(ctx, "api", "my-api"); err == nil {
+		t.Error("copyInstalledTemplate() replaced an existing copy")
+	}
+
+	// Customizing the copy is what it is for
+	writeTestFiles(t, templatePath("local/my-api"), map[string]string{
+		"main.go":        "package main\n\n// main is customized.\nfunc main() {}\n",
+		"README.md.tmpl": "# {{.ProjectName}}\n",
+	})
+	if err := verifyTemplates(ctx, []string{"my-api"}); err != nil {
+		t.Errorf("verifyTemplates() error = %v", err)
+	}
+
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	defer func(f *os.File) { os.Stdin = f }(os.Stdin)
+	os.Stdin = stdin
+
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOPROXY", "off")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := createProject(ctx, "orders", "my-api", createOptions{}); err != nil {
+		t.Fatalf("createProject() from the edited copy error = %v", err)
+	}
+	want := map[string]string{
+		"go.mod":    "module example\n\ngo 1.22\n",
+		"main.go":   "package main\n\n// main is customized.\nfunc main() {}\n",
+		"README.md": "# orders\n",
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join("orders", name))
+		if err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v, want %q", name, data, err, content)
+		}
+	}
+
+	// The original is still checked against what was installed
+	if _, err := checkTemplateIntegrity("github.com/acme/templates/api", false); err != nil {
+		t.Errorf("checkTemplateIntegrity() of the original error = %v", err)
+	}
+
+	// Copies are bundled as they are now, and the bundle checks out
+	bundle := filepath.Join(t.TempDir(), "templates.tar.gz")
+	if err := exportTemplates(ctx, []string{"my-api"}, bundle); err != nil {
+		t.Fatalf("exportTemplates() of the edited copy error = %v", err)
+	}
+	useTestHome(t)
+	if err := importTemplates(ctx, bundle, addOptions{}); err != nil {
+		t.Fatalf("importTemplates() error = %v", err)
+	}
+	if !templateExists("local/my-api") {
+		t.Error("importTemplates() did not install the copy")
+	}
+}
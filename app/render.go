@@ -0,0 +1,326 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/fatih/color"
+)
+
+// templateFuncs are available to templated files and paths.
+var templateFuncs = template.FuncMap{
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"replace": strings.ReplaceAll,
+}
+
+// templateValues works out the value of every variable of a template for a
+// new project: the built-in ones, those given on the command line, and the
+// defaults. Variables that are still unset are handed to missing.
+func templateValues(manifest *templateManifest, projectName string, given map[string]string, missing func(templateVariable) (any, error)) (map[string]any, error) {
+	values := map[string]any{builtinProjectNameVariable: projectName}
+
+	for name := range given {
+		if !slices.ContainsFunc(manifest.Variables, func(v templateVariable) bool { return v.Name == name }) {
+			return nil, fmt.Errorf(color.RedString("Error: template has no variable '%s'", name))
+		}
+	}
+
+	for _, variable := range manifest.Variables {
+		var value any
+		var err error
+
+		switch text, ok := given[variable.Name]; {
+		case ok:
+			value, err = variable.parse(text)
+		case variable.Default != nil:
+			// String defaults may refer to the variables declared before them
+			if text, ok := variable.Default.(string); ok {
+				text, err = renderString(variable.Name, text, values)
+				if err == nil {
+					value, err = variable.parse(text)
+				}
+			} else {
+				value, err = variable.convert(variable.Default)
+			}
+		default:
+			value, err = missing(variable)
+		}
+		if err != nil {
+			return nil, fmt.Errorf(color.RedString("Error: variable '%s': %v", variable.Name, err))
+		}
+
+		values[variable.Name] = value
+	}
+
+	return values, nil
+}
+
+// askVariable prompts for the value of a variable until a valid one is given.
+func askVariable(ctx context.Context) func(templateVariable) (any, error) {
+	return func(variable templateVariable) (any, error) {
+		for {
+			prompt := variable.Name
+			if variable.Description != "" {
+				prompt = fmt.Sprintf("%s (%s)", variable.Description, variable.Name)
+			}
+			if len(variable.Choices) > 0 {
+				prompt += fmt.Sprintf(" [%s]", strings.Join(variable.Choices, "/"))
+			}
+			fmt.Print(color.YellowString("%s: ", prompt))
+
+			text, err := readLine(ctx)
+			if err != nil {
+				return nil, err
+			}
+			value, err := variable.parse(text)
+			if err == nil {
+				return value, nil
+			}
+			fmt.Println(color.RedString("Error: %v", err))
+		}
+	}
+}
+
+// requireVariable fails for variables that have no value.
+func requireVariable(variable templateVariable) (any, error) {
+	return nil, fmt.Errorf("no value given; pass --var %s=<value>", variable.Name)
+}
+
+// placeholderVariable stands in for variables that have no value when a
+// template is only being previewed.
+func placeholderVariable(variable templateVariable) (any, error) {
+	switch variable.typeName() {
+	case "bool":
+		return false, nil
+	case "int":
+		return 0, nil
+	}
+	return "<" + variable.Name + ">", nil
+}
+
+// parseVariableFlags reads name=value pairs given with --var.
+func parseVariableFlags(flags []string) (map[string]string, error) {
+	given := map[string]string{}
+	for _, flag := range flags {
+		name, value, found := strings.Cut(flag, "=")
+		if !found || name == "" {
+			return nil, fmt.Errorf(color.RedString("Error: invalid variable %q, expected name=value", flag))
+		}
+		given[strings.TrimSpace(name)] = value
+	}
+	return given, nil
+}
+
+// planProject works out what the template in templateDir turns into in a
+// new project. Destinations are relative to the project root; directories
+// come before the files in them. Files named in .figoignore are left out,
+// and templates without a manifest are copied as they are.
+func planProject(templateDir string, manifest *templateManifest, values map[string]any) ([]fileJob, error) {
+	var jobs []fileJob
+
+	ignore, err := loadIgnoreRules(templateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	err = filepath.Walk(templateDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf(color.RedString("Error: accessing path %q: %v"), path, err)
+		}
+		if path == templateDir {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(templateDir, path)
+		if err != nil {
+			return fmt.Errorf(color.RedString("Error: getting relative path for %q: %v"), path, err)
+		}
+
+		if info.IsDir() && shouldSkipDir(info.Name()) {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && shouldSkipProjectFile(relPath) {
+			return nil
+		}
+		if ignore.ignored(relPath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		job := fileJob{src: path, dest: relPath, mode: info.Mode()}
+		if manifest.found {
+			job.dest, err = renderPath(relPath, values)
+			if err != nil {
+				return err
+			}
+			// A path that renders to nothing leaves the file or directory out
+			if job.dest == "" {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			job.render = !info.IsDir() && strings.HasSuffix(relPath, templateFileSuffix)
+		}
+
+		jobs = append(jobs, job)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// renderPath renders the templated segments of a relative path and drops
+// the .tmpl suffix. It returns "" when any segment renders to nothing.
+func renderPath(relPath string, values map[string]any) (string, error) {
+	segments := strings.Split(filepath.ToSlash(relPath), "/")
+	for i, segment := range segments {
+		if strings.Contains(segment, "{{") {
+			rendered, err := renderString(relPath, segment, values)
+			if err != nil {
+				return "", err
+			}
+			segment = strings.TrimSpace(rendered)
+		}
+		if i == len(segments)-1 {
+			segment = strings.TrimSuffix(segment, templateFileSuffix)
+		}
+		if segment == "" {
+			return "", nil
+		}
+		segments[i] = segment
+	}
+
+	rendered := filepath.FromSlash(strings.Join(segments, "/"))
+	if !filepath.IsLocal(rendered) {
+		return "", fmt.Errorf(color.RedString("Error: path %q renders outside of the project: %q", relPath, rendered))
+	}
+	return rendered, nil
+}
+
+func renderString(name string, text string, values map[string]any) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf(color.RedString("Error: parsing template %s: %v", name, err))
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, values); err != nil {
+		return "", fmt.Errorf(color.RedString("Error: rendering template %s: %v", name, err))
+	}
+	return out.String(), nil
+}
+
+// renderProject writes the planned files of a template into projectPath,
+// rendering the templated ones with values.
+func renderProject(ctx context.Context, jobs []fileJob, projectPath string, values map[string]any) error {
+	var files []fileJob
+	for _, job := range jobs {
+		job.dest = filepath.Join(projectPath, job.dest)
+		if job.mode.IsDir() {
+			if err := os.MkdirAll(job.dest, job.mode.Perm()|0700); err != nil {
+				return fmt.Errorf(color.RedString("Error: failed to create directory %q: %v"), job.dest, err)
+			}
+			continue
+		}
+		files = append(files, job)
+	}
+
+	return runFileJobs(ctx, files, func(job fileJob) error {
+		if !job.render {
+			return copyFile(job)
+		}
+		return renderFile(job, values)
+	})
+}
+
+func renderFile(job fileJob, values map[string]any) error {
+	text, err := os.ReadFile(job.src)
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: failed to read template file %q: %v"), job.src, err)
+	}
+
+	rendered, err := renderString(job.src, string(text), values)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(job.dest, []byte(rendered), job.mode.Perm()); err != nil {
+		return fmt.Errorf(color.RedString("Error: failed to write file %q: %v"), job.dest, err)
+	}
+	return nil
+}
+
+// renderHooks renders the commands of the hooks of a template with values.
+func renderHooks(manifest *templateManifest, values map[string]any) ([]templateHook, error) {
+	var hooks []templateHook
+	for _, hook := range manifest.Hooks {
+		args := make([]string, len(hook.Run))
+		for i, arg := range hook.Run {
+			rendered, err := renderString("hook", arg, values)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = rendered
+		}
+		hooks = append(hooks, templateHook{Name: hook.Name, Run: args})
+	}
+	return hooks, nil
+}
+
+// confirmHooks shows the hooks a template runs and asks whether to run
+// them, unless that was settled with --run-hooks. Hooks of templates that
+// are not signed and unchanged never run when signatures are required.
+func confirmHooks(ctx context.Context, hooks []templateHook, trusted bool, confirmed bool) (bool, error) {
+	if len(hooks) == 0 {
+		return false, nil
+	}
+	if config.RequireSignatures && !trusted {
+		return false, fmt.Errorf(color.RedString("Error: refusing to run the hooks of a template that is not verified, and signatures are required"))
+	}
+	if confirmed {
+		return true, nil
+	}
+
+	fmt.Println(color.YellowString("The template runs these commands in the new project:"))
+	for _, hook := range hooks {
+		fmt.Printf("  %s\n", strings.Join(hook.Run, " "))
+	}
+	return confirm(ctx, "Run them?", "--run-hooks")
+}
+
+// runHooks runs rendered hooks in a newly created project.
+func runHooks(ctx context.Context, hooks []templateHook, projectPath string) error {
+	for _, hook := range hooks {
+		if err := runCommand(ctx, hook.Run[0], hook.Run[1:], projectPath, hook.description()); err != nil {
+			return fmt.Errorf(color.RedString("Error: running hook '%s': %v", hook.description(), err))
+		}
+	}
+	return nil
+}
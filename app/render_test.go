@@ -0,0 +1,171 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		wantErr  bool
+	}{
+		{name: "valid", manifest: `{"go": "1.22", "variables": [{"name": "Docker", "type": "bool", "default": false}], "hooks": [{"run": ["go", "vet"]}]}`},
+		{name: "bad json", manifest: `{"variables": [`, wantErr: true},
+		{name: "bad go version", manifest: `{"go": "latest"}`, wantErr: true},
+		{name: "bad variable name", manifest: `{"variables": [{"name": "my-var"}]}`, wantErr: true},
+		{name: "duplicate variable", manifest: `{"variables": [{"name": "A"}, {"name": "A"}]}`, wantErr: true},
+		{name: "builtin variable", manifest: `{"variables": [{"name": "ProjectName"}]}`, wantErr: true},
+		{name: "unknown type", manifest: `{"variables": [{"name": "A", "type": "float"}]}`, wantErr: true},
+		{name: "bad default", manifest: `{"variables": [{"name": "A", "type": "int", "default": "many"}]}`, wantErr: true},
+		{name: "empty hook", manifest: `{"hooks": [{"name": "nothing"}]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFiles(t, dir, map[string]string{manifestFileName: tt.manifest})
+
+			manifest, err := loadManifest(dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !manifest.found {
+				t.Error("loadManifest() did not mark the manifest as found")
+			}
+		})
+	}
+
+	manifest, err := loadManifest(t.TempDir())
+	if err != nil || manifest.found {
+		t.Errorf("loadManifest() without a manifest = %+v, %v", manifest, err)
+	}
+}
+
+func TestTemplateValues(t *testing.T) {
+	manifest := &templateManifest{Variables: []templateVariable{
+		{Name: "Module", Default: "github.com/acme/{{.ProjectName}}"},
+		{Name: "Docker", Type: "bool", Default: "false"},
+		{Name: "Port", Type: "int"},
+	}}
+
+	tests := []struct {
+		name    string
+		given   map[string]string
+		want    map[string]any
+		wantErr bool
+	}{
+		{
+			name:  "defaults",
+			given: map[string]string{"Port": "8080"},
+			want:  map[string]any{"ProjectName": "app", "Module": "github.com/acme/app", "Docker": false, "Port": 8080},
+		},
+		{
+			name:  "given",
+			given: map[string]string{"Module": "example.com/app", "Docker": "true", "Port": "80"},
+			want:  map[string]any{"ProjectName": "app", "Module": "example.com/app", "Docker": true, "Port": 80},
+		},
+		{name: "missing", given: map[string]string{}, wantErr: true},
+		{name: "invalid", given: map[string]string{"Port": "eighty"}, wantErr: true},
+		{name: "unknown", given: map[string]string{"Port": "80", "Color": "red"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := templateValues(manifest, "app", tt.given, requireVariable)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("templateValues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("templateValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVariableFlags(t *testing.T) {
+	got, err := parseVariableFlags([]string{"Module=example.com/a=b", " Docker =true", "Empty="})
+	want := map[string]string{"Module": "example.com/a=b", "Docker": "true", "Empty": ""}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("parseVariableFlags() = %v, %v, want %v", got, err, want)
+	}
+
+	for _, flag := range []string{"Module", "=value"} {
+		if _, err := parseVariableFlags([]string{flag}); err == nil {
+			t.Errorf("parseVariableFlags(%q) succeeded", flag)
+		}
+	}
+}
+
+func TestRenderProject(t *testing.T) {
+	templateDir := t.TempDir()
+	writeTestFiles(t, templateDir, map[string]string{
+		manifestFileName:                      `{"variables": [{"name": "Docker", "type": "bool", "default": false}]}`,
+		"go.mod.tmpl":                         "module example.com/{{.ProjectName}}\n",
+		"README.md":                           "# {{.ProjectName}}\n",
+		"cmd/{{.ProjectName}}/main.go.tmpl":   "package main // {{upper .ProjectName}}\n",
+		"{{if .Docker}}Dockerfile{{end}}":     "FROM golang\n",
+		"{{if .Docker}}deploy{{end}}/app.yml": "app: {{.ProjectName}}\n",
+	})
+
+	manifest, err := loadManifest(templateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := templateValues(manifest, "app", nil, requireVariable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := planProject(templateDir, manifest, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	projectPath := t.TempDir()
+	if err := renderProject(context.Background(), jobs, projectPath, values); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"go.mod":           "module example.com/app\n",
+		"README.md":        "# {{.ProjectName}}\n",
+		"cmd/app/main.go":  "package main // APP\n",
+		manifestFileName:   "",
+		"Dockerfile":       "",
+		"deploy/app.yml":   "",
+		"{{.ProjectName}}": "",
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(projectPath, filepath.FromSlash(name)))
+		switch {
+		case content == "" && err == nil:
+			t.Errorf("%s was created", name)
+		case content != "" && (err != nil || string(data) != content):
+			t.Errorf("%s = %q, %v, want %q", name, data, err, content)
+		}
+	}
+}
+
+func TestRenderPathOutsideProject(t *testing.T) {
+	if _, err := renderPath("{{.Dir}}/main.go", map[string]any{"Dir": ".."}); err == nil {
+		t.Error("renderPath() allowed a path outside of the project")
+	}
+}
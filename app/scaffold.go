@@ -0,0 +1,179 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// scaffoldFiles are the files of a new template, besides its manifest.
+var scaffoldFiles = map[string]string{
+	ignoreFileName: `# Files for template authors, left out of generated projects
+README.md
+testdata/
+`,
+
+	"go.mod" + templateFileSuffix: `module {{.Module}}
+
+go 1.22
+`,
+
+	"main.go" + templateFileSuffix: `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("Hello from {{.ProjectName}}!")
+}
+`,
+
+	"{{if .Docker}}Dockerfile{{end}}": `FROM golang:1.22 AS build
+WORKDIR /src
+COPY . .
+RUN CGO_ENABLED=0 go build -o /out/{{.ProjectName}} .
+
+FROM gcr.io/distroless/static
+COPY --from=build /out/{{.ProjectName}} /{{.ProjectName}}
+ENTRYPOINT ["/{{.ProjectName}}"]
+`,
+
+	"testdata/default/answers.json": `{
+  "project_name": "example",
+  "variables": {
+    "Module": "github.com/acme/example",
+    "Docker": true
+  }
+}
+`,
+
+	"README.md": `# %[1]s
+
+A figo project template.
+
+## Layout
+
+- ` + "`figo.json`" + ` describes the template: its description, tags, the Go
+  version it needs, the variables asked for when creating a project and the
+  hooks run afterwards.
+- Files ending in ` + "`.tmpl`" + ` are rendered as Go templates, with the suffix
+  dropped. File and directory names containing ` + "`{{ }}`" + ` are rendered too; a
+  name that renders to nothing, like ` + "`{{if .Docker}}Dockerfile{{end}}`" + `,
+  leaves the file out.
+- ` + "`{{.ProjectName}}`" + ` and every variable of ` + "`figo.json`" + ` can be used.
+- ` + "`.figoignore`" + ` lists files, like this README and ` + "`testdata/`" + `, that
+  belong to the template but are not copied into projects.
+- ` + "`testdata/<case>/answers.json`" + ` holds the project name and variable values
+  of a test case, and ` + "`testdata/<case>/expected/`" + ` the project it should render.
+
+## Testing
+
+` + "```bash" + `
+figo templates lint .
+figo templates test --update .  # write the expected output of new test cases
+figo templates test --build .
+` + "```" + `
+
+## Trying it out
+
+Add the repository holding this template and create a project from it:
+
+` + "```bash" + `
+figo add-templates -u <repository> -d %[1]s
+figo templates info %[1]s
+figo create -n example -t %[1]s --var Docker=true
+` + "```" + `
+`,
+}
+
+// newTemplate creates a starter template called name in parent, which may
+// be a templates repository or any other directory.
+func newTemplate(name string, parent string) error {
+	if !isValidTemplateName(name) {
+		return fmt.Errorf(color.RedString("Error: invalid template name: %s", name))
+	}
+
+	dir := filepath.Join(parent, name)
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf(color.RedString("Error: '%s' already exists", dir))
+	}
+
+	manifest := templateManifest{
+		Description: "Describe what projects made from " + name + " are for",
+		Tags:        []string{"example"},
+		Go:          "1.22",
+		Variables: []templateVariable{
+			{Name: "Module", Description: "Go module path", Default: "github.com/example/{{.ProjectName}}"},
+			{Name: "Docker", Type: "bool", Description: "Add a Dockerfile", Default: false},
+		},
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: encoding manifest: %v", err))
+	}
+
+	files := map[string]string{manifestFileName: string(data) + "\n"}
+	for relPath, content := range scaffoldFiles {
+		if relPath == "README.md" {
+			content = fmt.Sprintf(content, name)
+		}
+		files[relPath] = content
+	}
+
+	paths := make([]string, 0, len(files))
+	for relPath := range files {
+		paths = append(paths, relPath)
+	}
+	sort.Strings(paths)
+
+	for _, relPath := range paths {
+		target := filepath.Join(dir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf(color.RedString("Error: creating directory %q: %v", filepath.Dir(target), err))
+		}
+		if err := os.WriteFile(target, []byte(files[relPath]), 0644); err != nil {
+			return fmt.Errorf(color.RedString("Error: writing %q: %v", target, err))
+		}
+	}
+
+	fmt.Print(color.GreenString("Template '%s' created in %s\n", name, dir))
+	printFileTree(paths)
+
+	// Say how to publish it, depending on whether it is part of a repository
+	if inGitRepository(parent) {
+		fmt.Printf("\nCommit it, then add it with 'figo add-templates -u <repository> -d %s'\n", name)
+	} else {
+		fmt.Printf("\nTo publish it on its own, make %s a git repository and add it with 'figo add-templates -u <repository>'\n", dir)
+	}
+	return nil
+}
+
+// inGitRepository reports whether dir is inside a git working tree.
+func inGitRepository(dir string) bool {
+	if !gitInstalled() {
+		return false
+	}
+	cmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	return err == nil && strings.TrimSpace(string(output)) == "true"
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewTemplate(t *testing.T) {
+	parent := t.TempDir()
+	if err := newTemplate("api", parent); err != nil {
+		t.Fatalf("newTemplate() error = %v", err)
+	}
+	templateDir := filepath.Join(parent, "api")
+
+	if !isTemplateDir(templateDir) {
+		t.Fatal("newTemplate() did not create a template")
+	}
+	manifest, err := loadManifest(templateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The starter template renders for both values of its variables, and
+	// leaves out the files meant for its authors
+	for _, docker := range []string{"true", "false"} {
+		values, err := templateValues(manifest, "example", map[string]string{"Docker": docker}, requireVariable)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jobs, err := planProject(templateDir, manifest, values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		projectPath := t.TempDir()
+		if err := renderProject(context.Background(), jobs, projectPath, values); err != nil {
+			t.Fatal(err)
+		}
+
+		module, err := os.ReadFile(filepath.Join(projectPath, "go.mod"))
+		if err != nil || string(module) != "module github.com/example/example\n\ngo 1.22\n" {
+			t.Errorf("Docker=%s: go.mod = %q, %v", docker, module, err)
+		}
+		for name, want := range map[string]bool{
+			"main.go":        true,
+			"Dockerfile":     docker == "true",
+			"README.md":      false,
+			"testdata":       false,
+			ignoreFileName:   false,
+			manifestFileName: false,
+		} {
+			if _, err := os.Stat(filepath.Join(projectPath, name)); (err == nil) != want {
+				t.Errorf("Docker=%s: %s exists = %v, want %v", docker, name, err == nil, want)
+			}
+		}
+	}
+
+	if err := newTemplate("api", parent); err == nil {
+		t.Error("newTemplate() overwrote an existing template")
+	}
+	if err := newTemplate("../api", parent); err == nil {
+		t.Error("newTemplate() accepted an invalid name")
+	}
+}
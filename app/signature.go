@@ -0,0 +1,251 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Templates are signed by a figo.sig file at their root holding a detached
+// signature over their content hash, as printed by 'figo templates hash',
+// followed by a newline. Two kinds of signature are accepted:
+//
+//   - SSH signatures made with an ed25519 key and the "figo" namespace:
+//     ssh-keygen -Y sign -n figo -f ~/.ssh/id_ed25519 message
+//   - raw ed25519 signatures, base64 encoded on a single line
+//
+// Signatures are checked against the trusted keys in the config file.
+
+// trustedKey is a publisher key in the keyring of the config file. Key is
+// either an SSH public key line ("ssh-ed25519 AAAA... comment") or a raw
+// ed25519 public key ("ed25519:<base64>").
+type trustedKey struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+var errNoSignature = errors.New("template is not signed")
+
+// publicKey decodes the ed25519 key, along with its SSH wire encoding for
+// SSH keys.
+func (k trustedKey) publicKey() (ed25519.PublicKey, []byte, error) {
+	if encoded, ok := strings.CutPrefix(k.Key, "ed25519:"); ok {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, nil, fmt.Errorf("invalid ed25519 key %q", k.Name)
+		}
+		return ed25519.PublicKey(key), nil, nil
+	}
+
+	fields := strings.Fields(k.Key)
+	if len(fields) < 2 || fields[0] != "ssh-ed25519" {
+		return nil, nil, fmt.Errorf("key %q is not an ed25519 key", k.Name)
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid SSH key %q: %v", k.Name, err)
+	}
+
+	reader := sshReader{data: blob}
+	keyType, key := reader.string(), reader.string()
+	if reader.err != nil || string(keyType) != "ssh-ed25519" || len(key) != ed25519.PublicKeySize {
+		return nil, nil, fmt.Errorf("invalid SSH key %q", k.Name)
+	}
+	return ed25519.PublicKey(key), blob, nil
+}
+
+// verifyTemplateSignature checks the signature of the template in dir and
+// returns the name of the trusted key that made it. Templates without a
+// signature return errNoSignature.
+func verifyTemplateSignature(dir string) (string, error) {
+	signature, err := os.ReadFile(filepath.Join(dir, signatureFileName))
+	if os.IsNotExist(err) {
+		return "", errNoSignature
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading signature: %v", err)
+	}
+
+	hash, err := hashTemplate(dir)
+	if err != nil {
+		return "", err
+	}
+	return verifySignature(signature, hash)
+}
+
+// verifySignature checks a figo.sig over the content hash of a template and
+// returns the name of the trusted key that made it.
+func verifySignature(signature []byte, hash string) (string, error) {
+	message := []byte(hash + "\n")
+
+	if bytes.Contains(signature, []byte("-----BEGIN SSH SIGNATURE-----")) {
+		return verifySSHSignature(signature, message)
+	}
+	return verifyRawSignature(signature, message)
+}
+
+func verifyRawSignature(signature, message []byte) (string, error) {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return "", fmt.Errorf("invalid signature")
+	}
+
+	for _, trusted := range config.TrustedKeys {
+		key, sshBlob, err := trusted.publicKey()
+		if err != nil || sshBlob != nil {
+			continue
+		}
+		if ed25519.Verify(key, message, sig) {
+			return trusted.Name, nil
+		}
+	}
+	return "", fmt.Errorf("signature does not match any trusted key")
+}
+
+// verifySSHSignature checks an armored SSH signature (the SSHSIG format of
+// ssh-keygen -Y sign) made with an ed25519 key.
+func verifySSHSignature(armored, message []byte) (string, error) {
+	var encoded strings.Builder
+	for _, line := range strings.Split(string(armored), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "-----") {
+			encoded.WriteString(line)
+		}
+	}
+	blob, err := base64.StdEncoding.DecodeString(encoded.String())
+	if err != nil || !bytes.HasPrefix(blob, []byte("SSHSIG")) {
+		return "", fmt.Errorf("invalid SSH signature")
+	}
+
+	reader := sshReader{data: blob[len("SSHSIG"):]}
+	version := reader.uint32()
+	publicKey, namespace, reserved, hashAlgorithm, sigBlob := reader.string(), reader.string(), reader.string(), reader.string(), reader.string()
+	if reader.err != nil || version != 1 {
+		return "", fmt.Errorf("invalid SSH signature")
+	}
+	if string(namespace) != signatureNamespace {
+		return "", fmt.Errorf("SSH signature is for namespace %q, expected %q", namespace, signatureNamespace)
+	}
+
+	sigReader := sshReader{data: sigBlob}
+	sigFormat, sig := sigReader.string(), sigReader.string()
+	if sigReader.err != nil || string(sigFormat) != "ssh-ed25519" {
+		return "", fmt.Errorf("unsupported SSH signature %q, only ed25519 keys are supported", sigFormat)
+	}
+
+	var digest []byte
+	switch string(hashAlgorithm) {
+	case "sha256":
+		sum := sha256.Sum256(message)
+		digest = sum[:]
+	case "sha512":
+		sum := sha512.Sum512(message)
+		digest = sum[:]
+	default:
+		return "", fmt.Errorf("unsupported SSH signature hash %q", hashAlgorithm)
+	}
+
+	// The signature covers the magic, namespace, reserved field, hash
+	// algorithm and message digest
+	var signed bytes.Buffer
+	signed.WriteString("SSHSIG")
+	for _, field := range [][]byte{namespace, reserved, hashAlgorithm, digest} {
+		binary.Write(&signed, binary.BigEndian, uint32(len(field)))
+		signed.Write(field)
+	}
+
+	for _, trusted := range config.TrustedKeys {
+		key, sshBlob, err := trusted.publicKey()
+		if err != nil || !bytes.Equal(sshBlob, publicKey) {
+			continue
+		}
+		if ed25519.Verify(key, signed.Bytes(), sig) {
+			return trusted.Name, nil
+		}
+		return "", fmt.Errorf("invalid signature by %q", trusted.Name)
+	}
+	return "", fmt.Errorf("signed by a key that is not trusted")
+}
+
+// checkSignature applies the signature policy to a template about to be
+// installed: signed templates must be signed by a trusted key, and when the
+// config requires signatures, unsigned ones are refused. It returns the
+// name of the signer, if any.
+func checkSignature(name, dir string) (string, error) {
+	signer, err := verifyTemplateSignature(dir)
+	return signaturePolicy(name, signer, err)
+}
+
+// checkSignatureOf is checkSignature for a template that is not on disk,
+// given its signature, nil when it has none, and its content hash.
+func checkSignatureOf(name string, signature []byte, hash string) (string, error) {
+	if signature == nil {
+		return signaturePolicy(name, "", errNoSignature)
+	}
+	signer, err := verifySignature(signature, hash)
+	return signaturePolicy(name, signer, err)
+}
+
+func signaturePolicy(name string, signer string, err error) (string, error) {
+	switch {
+	case err == nil:
+		fmt.Print(color.GreenString("Template '%s' is signed by '%s'\n", name, signer))
+		return signer, nil
+	case errors.Is(err, errNoSignature) && !config.RequireSignatures:
+		return "", nil
+	case errors.Is(err, errNoSignature):
+		return "", fmt.Errorf(color.RedString("Error: template '%s' is not signed, and signatures are required", name))
+	}
+	return "", fmt.Errorf(color.RedString("Error: template '%s': %v", name, err))
+}
+
+// sshReader reads the length-prefixed fields of the SSH wire format.
+type sshReader struct {
+	data []byte
+	err  error
+}
+
+func (r *sshReader) uint32() uint32 {
+	if r.err != nil || len(r.data) < 4 {
+		r.err = errors.New("truncated data")
+		return 0
+	}
+	value := binary.BigEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return value
+}
+
+func (r *sshReader) string() []byte {
+	length := r.uint32()
+	if r.err != nil || uint32(len(r.data)) < length {
+		r.err = errors.New("truncated data")
+		return nil
+	}
+	value := r.data[:length]
+	r.data = r.data[length:]
+	return value
+}
@@ -0,0 +1,151 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sshString encodes fields in the SSH wire format.
+func sshString(fields ...[]byte) []byte {
+	var out bytes.Buffer
+	for _, field := range fields {
+		binary.Write(&out, binary.BigEndian, uint32(len(field)))
+		out.Write(field)
+	}
+	return out.Bytes()
+}
+
+// sshSignature signs message the way ssh-keygen -Y sign does.
+func sshSignature(t *testing.T, key ed25519.PrivateKey, namespace string, hashAlgorithm string, message []byte) []byte {
+	t.Helper()
+
+	var digest []byte
+	switch hashAlgorithm {
+	case "sha256":
+		sum := sha256.Sum256(message)
+		digest = sum[:]
+	default:
+		sum := sha512.Sum512(message)
+		digest = sum[:]
+	}
+
+	signed := append([]byte("SSHSIG"), sshString([]byte(namespace), nil, []byte(hashAlgorithm), digest)...)
+	sig := sshString([]byte("ssh-ed25519"), ed25519.Sign(key, signed))
+
+	var blob bytes.Buffer
+	blob.WriteString("SSHSIG")
+	binary.Write(&blob, binary.BigEndian, uint32(1))
+	blob.Write(sshString(sshPublicKey(key), []byte(namespace), nil, []byte(hashAlgorithm), sig))
+
+	return []byte("-----BEGIN SSH SIGNATURE-----\n" + base64.StdEncoding.EncodeToString(blob.Bytes()) + "\n-----END SSH SIGNATURE-----\n")
+}
+
+func sshPublicKey(key ed25519.PrivateKey) []byte {
+	return sshString([]byte("ssh-ed25519"), key.Public().(ed25519.PublicKey))
+}
+
+func TestCheckSignature(t *testing.T) {
+	defer func(c *figoConfig) { config = c }(config)
+
+	newKey := func() ed25519.PrivateKey {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	rawKey, sshKey, untrustedKey := newKey(), newKey(), newKey()
+	trusted := []trustedKey{
+		{Name: "raw", Key: "ed25519:" + base64.StdEncoding.EncodeToString(rawKey.Public().(ed25519.PublicKey))},
+		{Name: "ssh", Key: "ssh-ed25519 " + base64.StdEncoding.EncodeToString(sshPublicKey(sshKey)) + " publisher@example.com"},
+	}
+
+	raw := func(key ed25519.PrivateKey) func([]byte) []byte {
+		return func(message []byte) []byte {
+			return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, message)) + "\n")
+		}
+	}
+	ssh := func(key ed25519.PrivateKey, namespace, hashAlgorithm string) func([]byte) []byte {
+		return func(message []byte) []byte {
+			return sshSignature(t, key, namespace, hashAlgorithm, message)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		sign       func(message []byte) []byte
+		tamper     bool
+		require    bool
+		wantSigner string
+		wantErr    bool
+	}{
+		{name: "raw signature", sign: raw(rawKey), wantSigner: "raw"},
+		{name: "ssh signature", sign: ssh(sshKey, signatureNamespace, "sha512"), wantSigner: "ssh"},
+		{name: "ssh signature with sha256", sign: ssh(sshKey, signatureNamespace, "sha256"), wantSigner: "ssh"},
+		{name: "wrong namespace", sign: ssh(sshKey, "git", "sha512"), wantErr: true},
+		{name: "wrong hash algorithm", sign: ssh(sshKey, signatureNamespace, "md5"), wantErr: true},
+		{name: "tampered raw", sign: raw(rawKey), tamper: true, wantErr: true},
+		{name: "tampered ssh", sign: ssh(sshKey, signatureNamespace, "sha512"), tamper: true, wantErr: true},
+		{name: "untrusted raw key", sign: raw(untrustedKey), wantErr: true},
+		{name: "untrusted ssh key", sign: ssh(untrustedKey, signatureNamespace, "sha512"), wantErr: true},
+		{name: "garbage", sign: func([]byte) []byte { return []byte("not a signature\n") }, wantErr: true},
+		{name: "unsigned"},
+		{name: "unsigned when required", require: true, wantErr: true},
+		{name: "signed when required", sign: raw(rawKey), require: true, wantSigner: "raw"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config = &figoConfig{TrustedKeys: trusted, RequireSignatures: tt.require}
+
+			dir := t.TempDir()
+			writeTestFiles(t, dir, map[string]string{
+				"go.mod":  "module example.com/api\n",
+				"main.go": "package main\n",
+			})
+
+			if tt.sign != nil {
+				hash, err := hashTemplate(dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, signatureFileName), tt.sign([]byte(hash+"\n")), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.tamper {
+				writeTestFiles(t, dir, map[string]string{"main.go": "package main\n\nfunc init() {}\n"})
+			}
+
+			signer, err := checkSignature("api", dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if signer != tt.wantSigner {
+				t.Errorf("checkSignature() signer = %q, want %q", signer, tt.wantSigner)
+			}
+		})
+	}
+}
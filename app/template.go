@@ -15,28 +15,45 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/eiannone/keyboard"
 	"github.com/fatih/color"
 )
 
-func extractAllTemplates(sourceDir string, repoName string) error {
+// templateCandidate is a template found in a fetched repository, along with
+// the name it will be installed under.
+type templateCandidate struct {
+	name         string
+	dir          string
+	subdirectory string
+	// signer names the trusted key the template is signed with
+	signer string
+}
 
-	if isGoModule(sourceDir) {
-		destPath := filepath.Join(templatesDirectory, repoName)
-		if err := copyTemplate(sourceDir, destPath); err != nil {
-			fmt.Printf(color.RedString("Error:Error copying template '%s': %v\n"), repoName, err)
-		}
-		fmt.Printf(color.GreenString("Template '%s' extracted successfully\n"), repoName)
+// findTemplates lists the templates in a fetched repository: the repository
+// itself when it is a Go module, and each top-level directory that is one.
+// Their names are placed under namespace.
+func findTemplates(sourceDir string, namespace string) ([]templateCandidate, error) {
+	var candidates []templateCandidate
+
+	if isTemplateDir(sourceDir) {
+		candidates = append(candidates, templateCandidate{name: namespace + "/" + path.Base(namespace), dir: sourceDir})
 	}
 
 	files, err := os.ReadDir(sourceDir)
 	if err != nil {
-		return fmt.Errorf(color.RedString("Error: reading repository directory: %v"), err)
+		return nil, fmt.Errorf(color.RedString("Error: reading repository directory: %v"), err)
 	}
 
 	for _, file := range files {
@@ -48,32 +65,61 @@ func extractAllTemplates(sourceDir string, repoName string) error {
 				continue
 			}
 
-			// Check if the directory contains a Go module
-			if isGoModule(templateDir) {
-				// Get the base directory name (to use as template name)
-				templateName := fmt.Sprintf("%s_%s", repoName, file.Name())
+			// Check if the directory contains a template
+			if isTemplateDir(templateDir) {
+				candidates = append(candidates, templateCandidate{
+					name:         namespace + "/" + file.Name(),
+					dir:          templateDir,
+					subdirectory: file.Name(),
+				})
+			}
+		}
+	}
 
-				// Copy template directory to local templates directory
-				destPath := filepath.Join(templatesDirectory, templateName)
-				if err := copyTemplate(templateDir, destPath); err != nil {
-					fmt.Printf(color.RedString("Error: copying template '%s': %v\n"), templateName, err)
-					continue
-				}
+	return candidates, nil
+}
 
-				fmt.Printf(color.GreenString("Template '%s' extracted successfully\n"), templateName)
+// installTemplates copies templates into the templates directory, cleanly
+// replacing any existing template of the same name, and records them.
+func installTemplates(ctx context.Context, candidates []templateCandidate, source templateSource, registry *templateRegistry) error {
+	for _, candidate := range candidates {
+		if err := replaceTemplate(ctx, candidate.dir, candidate.name); err != nil {
+			if ctx.Err() != nil {
+				return err
 			}
+			fmt.Printf(color.RedString("Error: copying template '%s': %v\n"), candidate.name, err)
+			continue
+		}
+
+		if err := registry.record(candidate.name, source, candidate.subdirectory); err != nil {
+			return err
 		}
+		registry.Templates[candidate.name].SignedBy = candidate.signer
+
+		fmt.Printf(color.GreenString("Template '%s' extracted successfully\n"), candidate.name)
 	}
 
 	return nil
 }
-func copyTemplate(src, dest string) error {
+
+// copyTemplate copies a template into the templates directory.
+func copyTemplate(ctx context.Context, src, dest string) error {
+	return copyFiles(ctx, src, dest, func(relPath string) bool {
+		return shouldSkipFile(filepath.Base(relPath))
+	})
+}
+
+// copyFiles copies the tree at src into dest, leaving out skipped directories
+// and the files for which skip, given the path relative to src, returns true.
+func copyFiles(ctx context.Context, src, dest string, skip func(relPath string) bool) error {
 	// Create destination directory if it doesn't exist
 	if err := os.MkdirAll(dest, 0755); err != nil {
 		return fmt.Errorf(color.RedString("Error: failed to create destination directory: %v"), err)
 	}
 
-	// Traverse source directory
+	var jobs []fileJob
+
+	// Traverse source directory, creating directories as we go and queueing files
 	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return fmt.Errorf(color.RedString("Error: accessing path %q: %v"), path, err)
@@ -97,37 +143,133 @@ func copyTemplate(src, dest string) error {
 				return fmt.Errorf(color.RedString("Error: failed to create directory %q: %v"), destPath, err)
 			}
 		} else {
-			if shouldSkipFile(info.Name()) {
+			if skip(relPath) {
 				return nil
 			}
-			// Copy file to destination
-			srcFile, err := os.Open(path)
-			if err != nil {
-				return fmt.Errorf(color.RedString("Error: failed to open source file %q: %v"), path, err)
-			}
-			defer srcFile.Close()
+			jobs = append(jobs, fileJob{src: path, dest: destPath, mode: info.Mode()})
+		}
 
-			destFile, err := os.Create(destPath)
-			if err != nil {
-				return fmt.Errorf(color.RedString("Error: failed to create destination file %q: %v"), destPath, err)
-			}
-			defer destFile.Close()
+		return nil
+	})
+	if err == nil {
+		err = runFileJobs(ctx, jobs, copyFile)
+	}
 
-			if _, err := io.Copy(destFile, srcFile); err != nil {
-				return fmt.Errorf(color.RedString("Error: failed to copy file %q to %q: %v"), path, destPath, err)
-			}
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: copying template: %v"), err)
+	}
+
+	return nil
+}
+
+// replaceTemplate installs the template in src under name, replacing any
+// existing template of that name so that no stale files survive. The new
+// copy is staged next to the old one and swapped in once it is complete.
+func replaceTemplate(ctx context.Context, src, name string) error {
+	destPath := templatePath(name)
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf(color.RedString("Error: creating templates directory: %v", err))
+	}
+
+	staging, err := os.MkdirTemp(templatesDirectory, ".staging-*")
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: creating staging directory: %v", err))
+	}
+	defer os.RemoveAll(staging)
 
-			// Preserve file mode
-			if err := os.Chmod(destPath, info.Mode()); err != nil {
-				return fmt.Errorf(color.RedString("Error: failed to set file mode for %q: %v"), destPath, err)
+	if err := copyTemplate(ctx, src, staging); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(destPath); err != nil {
+		return fmt.Errorf(color.RedString("Error: removing template '%s': %v", name, err))
+	}
+	if err := os.Rename(staging, destPath); err != nil {
+		return fmt.Errorf(color.RedString("Error: installing template '%s': %v", name, err))
+	}
+	return nil
+}
+
+// fileJob describes a single file to be written into a destination directory.
+type fileJob struct {
+	src    string
+	dest   string
+	mode   os.FileMode
+	render bool
+}
+
+// runFileJobs processes jobs with a bounded pool of workers. It stops handing
+// out new jobs after the first failure, or once ctx is canceled, and returns
+// that failure.
+func runFileJobs(ctx context.Context, jobs []fileJob, process func(fileJob) error) error {
+	workers := min(runtime.NumCPU()*2, maxFileWorkers, len(jobs))
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	queue := make(chan fileJob)
+	failed := make(chan struct{})
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				if err := process(job); err != nil {
+					once.Do(func() {
+						firstErr = err
+						close(failed)
+					})
+				}
 			}
+		}()
+	}
+
+feed:
+	for _, job := range jobs {
+		select {
+		case queue <- job:
+		case <-failed:
+			break feed
+		case <-ctx.Done():
+			once.Do(func() {
+				firstErr = ctx.Err()
+				close(failed)
+			})
+			break feed
 		}
+	}
+	close(queue)
+	wg.Wait()
 
-		return nil
-	})
+	return firstErr
+}
 
+func copyFile(job fileJob) error {
+	srcFile, err := os.Open(job.src)
 	if err != nil {
-		return fmt.Errorf(color.RedString("Error: copying template: %v"), err)
+		return fmt.Errorf(color.RedString("Error: failed to open source file %q: %v"), job.src, err)
+	}
+	defer srcFile.Close()
+
+	destFile, err := os.Create(job.dest)
+	if err != nil {
+		return fmt.Errorf(color.RedString("Error: failed to create destination file %q: %v"), job.dest, err)
+	}
+
+	if _, err := io.Copy(destFile, srcFile); err != nil {
+		destFile.Close()
+		return fmt.Errorf(color.RedString("Error: failed to copy file %q to %q: %v"), job.src, job.dest, err)
+	}
+	if err := destFile.Close(); err != nil {
+		return fmt.Errorf(color.RedString("Error: failed to write file %q: %v"), job.dest, err)
+	}
+
+	// Preserve file mode
+	if err := os.Chmod(job.dest, job.mode); err != nil {
+		return fmt.Errorf(color.RedString("Error: failed to set file mode for %q: %v"), job.dest, err)
 	}
 
 	return nil
@@ -145,87 +287,277 @@ func printTemplateOptions(templates []string, currentIndex int) {
 
 }
 
-func downloadTemplates(url string) error {
+// addOptions controls how templates are added from a repository.
+type addOptions struct {
+	// Ref is the branch, tag or commit to add; empty means the default branch
+	Ref string
+	// Dir limits the add to the template in this top-level directory
+	Dir string
+	// Overwrite replaces installed templates of the same name
+	Overwrite bool
+	// Skip leaves installed templates of the same name alone
+	Skip bool
+	// Rename installs a single template under this name instead
+	Rename string
+	// Checksum is the digest the single template added must have
+	Checksum string
+}
+
+// downloadTemplates installs the templates found in the repository at url.
+func downloadTemplates(ctx context.Context, url string, opts addOptions) error {
 
 	if url == "" {
-		fmt.Print(color.YellowString("Warning: No URL specified, using default repository: %s\n", defaultTemplatesRepoURL))
-		url = defaultTemplatesRepoURL
+		if opts.Ref != "" || opts.Dir != "" || opts.Rename != "" {
+			return fmt.Errorf(color.RedString("Error: --ref, --dir and --rename need a repository URL"))
+		}
+		fmt.Print(color.YellowString("Warning: No URL specified, using the configured template sources\n"))
+		return installTemplateSources(ctx)
+	}
+
+	// A ref may also be given as a suffix of the URL, as in <url>@v2.1.0
+	url, urlRef := splitRepoRef(url)
+	if urlRef != "" {
+		if opts.Ref != "" && opts.Ref != urlRef {
+			return fmt.Errorf(color.RedString("Error: conflicting refs '%s' and '%s'", urlRef, opts.Ref))
+		}
+		opts.Ref = urlRef
+	}
+
+	if opts.Dir != "" && !isValidTemplateName(opts.Dir) {
+		return fmt.Errorf(color.RedString("Error: template directory '%s' must be a top-level directory of the repository", opts.Dir))
+	}
+	if opts.Overwrite && opts.Skip {
+		return fmt.Errorf(color.RedString("Error: --overwrite and --skip cannot be used together"))
+	}
+	if opts.Rename != "" && !isValidTemplateName(opts.Rename) {
+		return fmt.Errorf(color.RedString("Error: invalid template name: %s", opts.Rename))
 	}
 
 	fmt.Print(color.YellowString("Downloading templates from repository: %s ...\n", url))
 
-	repoName, err := extractRepoNameFromURL(url)
+	namespace, err := templateNamespace(url)
 	if err != nil {
 		return fmt.Errorf(color.RedString("Error: extracting repository name: %v"), err)
 	}
 
-	repoDir := repoDirectory
-	if err := gitClone(url, repoDir); err != nil {
-		return fmt.Errorf(color.RedString("Error: cloning repository: %v", err))
+	repoDir, source, err := fetchRepository(ctx, url, opts.Ref, opts.Dir)
+	if err != nil {
+		return err
 	}
 	defer os.RemoveAll(repoDir)
 
-	if err := extractAllTemplates(repoDir, repoName); err != nil {
-		return fmt.Errorf(color.RedString("Error: extracting templates: %v", err))
+	candidates, err := findTemplates(repoDir, namespace)
+	if err != nil {
+		return err
+	}
+	if opts.Dir != "" && len(candidates) == 0 {
+		return fmt.Errorf(color.RedString("Error: no template found in directory '%s' of %s", opts.Dir, url))
+	}
+	if opts.Checksum != "" {
+		// Only the template the checksum is for is installed
+		candidate, err := verifyChecksum(candidates, opts.Dir, opts.Checksum)
+		if err != nil {
+			return err
+		}
+		candidates = []templateCandidate{candidate}
+	}
+	for i := range candidates {
+		if candidates[i].signer, err = checkSignature(candidates[i].name, candidates[i].dir); err != nil {
+			return err
+		}
+	}
+
+	unlock, err := lockTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Settle every name collision before anything is written
+	candidates, err = resolveCollisions(ctx, candidates, opts)
+	if err != nil {
+		return err
+	}
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	installErr := installTemplates(ctx, candidates, source, registry)
+
+	// Record whatever was installed, even if installation stopped part way
+	if err := registry.save(); err != nil {
+		return err
+	}
+	if installErr != nil {
+		return fmt.Errorf(color.RedString("Error: extracting templates: %v", installErr))
 	}
 
 	return nil
 }
 
-func deleteAllTemplates() error {
-
-	if _, err := os.Stat(templatesDirectory); os.IsNotExist(err) {
-		return fmt.Errorf(color.RedString("Error: templates directory does not exist"))
+// verifyChecksum checks that the template in the top-level directory dir of
+// the repository, or the repository itself when dir is empty, has the
+// expected digest, and returns it.
+func verifyChecksum(candidates []templateCandidate, dir string, checksum string) (templateCandidate, error) {
+	i := slices.IndexFunc(candidates, func(c templateCandidate) bool { return c.subdirectory == dir })
+	if i < 0 {
+		if dir == "" {
+			return templateCandidate{}, fmt.Errorf(color.RedString("Error: the repository is not a template itself; select a template with a checksum by its directory"))
+		}
+		return templateCandidate{}, fmt.Errorf(color.RedString("Error: no template found in directory '%s'", dir))
 	}
 
-	err := os.RemoveAll(templatesDirectory)
+	hash, err := hashTemplate(candidates[i].dir)
 	if err != nil {
-		return fmt.Errorf(color.RedString("Error: deleting templates directory: %v", err))
+		return templateCandidate{}, err
+	}
+	if hash != checksum {
+		return templateCandidate{}, fmt.Errorf(color.RedString("Error: checksum mismatch for template '%s':\n  expected %s\n  got      %s", candidates[i].name, checksum, hash))
 	}
+	return candidates[i], nil
+}
 
-	fmt.Println(color.GreenString("All templates deleted successfully"))
-	return nil
+// resolveCollisions decides what happens to templates whose name is already
+// installed, following opts or, on a terminal, asking. Without either it
+// fails, listing every collision, so that nothing is written.
+func resolveCollisions(ctx context.Context, candidates []templateCandidate, opts addOptions) ([]templateCandidate, error) {
+	if opts.Rename != "" {
+		if len(candidates) != 1 {
+			return nil, fmt.Errorf(color.RedString("Error: --rename needs exactly one template, found %d; select one with --dir", len(candidates)))
+		}
+		candidates[0].name = renameTemplateID(candidates[0].name, opts.Rename)
+	}
+
+	var resolved []templateCandidate
+	var collisions []string
+	interactive := isTerminal(os.Stdin)
+
+	for _, candidate := range candidates {
+		if !templateExists(candidate.name) || opts.Overwrite {
+			resolved = append(resolved, candidate)
+			continue
+		}
+
+		switch {
+		case opts.Skip:
+			fmt.Print(color.YellowString("Skipping template '%s': already installed\n", candidate.name))
+		case !interactive:
+			collisions = append(collisions, candidate.name)
+		default:
+			name, err := askCollision(ctx, candidate.name)
+			if err != nil {
+				return nil, err
+			}
+			if name != "" {
+				candidate.name = name
+				resolved = append(resolved, candidate)
+			}
+		}
+	}
+
+	if len(collisions) > 0 {
+		return nil, fmt.Errorf(color.RedString("Error: templates already installed: %s\nUse --overwrite, --rename or --skip to add them", strings.Join(collisions, ", ")))
+	}
+	return resolved, nil
 }
 
-func deleteTemplateByName(templateName string) error {
+// askCollision asks what to do about a template that is already installed
+// and returns the name to install it under, or "" to skip it.
+func askCollision(ctx context.Context, name string) (string, error) {
+	for {
+		fmt.Print(color.YellowString("Template '%s' already exists. [o]verwrite, [r]ename or [s]kip? ", name))
+		answer, err := readLine(ctx)
+		if err != nil {
+			return "", err
+		}
 
-	templatePath := filepath.Join(templatesDirectory, templateName)
-	if _, err := os.Stat(templatePath); os.IsNotExist(err) {
-		return fmt.Errorf(color.RedString("Error: template '%s' not found", templateName))
+		switch strings.ToLower(answer) {
+		case "o", "overwrite":
+			return name, nil
+		case "s", "skip":
+			return "", nil
+		case "r", "rename":
+			fmt.Print(color.YellowString("New name for template '%s': ", name))
+			newName, err := readLine(ctx)
+			if err != nil {
+				return "", err
+			}
+			if !isValidTemplateName(newName) {
+				fmt.Println(color.RedString("Error: invalid template name: %s", newName))
+				continue
+			}
+			name = renameTemplateID(name, newName)
+			if !templateExists(name) {
+				return name, nil
+			}
+		}
 	}
+}
 
-	err := os.RemoveAll(templatePath)
-	if err != nil {
-		return fmt.Errorf(color.RedString("Error: deleting template: %v", err))
+func templateExists(name string) bool {
+	_, err := os.Stat(templatePath(name))
+	return err == nil
+}
 
+// renameTemplateID gives the template id a new name within its namespace.
+func renameTemplateID(id string, name string) string {
+	if namespace := path.Dir(id); namespace != "." {
+		return namespace + "/" + name
 	}
-	fmt.Print(color.GreenString("template '%s' deleted successfully\n", templateName))
-	return nil
+	return name
 }
 
-func listTemplates() ([]string, error) {
+// listTemplates returns the installed templates, downloading the default
+// templates first when there is no templates directory yet.
+func listTemplates(ctx context.Context) ([]string, error) {
 
 	if _, err := os.Stat(templatesDirectory); os.IsNotExist(err) {
 		if err := os.MkdirAll(templatesDirectory, 0755); err != nil {
 			return nil, fmt.Errorf(color.RedString("Error: creating templates directory: %v", err))
 		}
-		if err := downloadTemplates(defaultTemplatesRepoURL); err != nil {
-			return nil, err
+		if len(config.templateSources()) > 0 {
+			if err := installTemplateSources(ctx); err != nil {
+				return nil, err
+			}
 		}
 
 	}
 
+	return installedTemplates()
+}
+
+// installedTemplates returns the identifiers of the installed templates in
+// lexical order: every Go module found below the templates directory.
+func installedTemplates() ([]string, error) {
 	var templates []string
 
-	files, err := os.ReadDir(templatesDirectory)
-	if err != nil {
-		return nil, fmt.Errorf(color.RedString("Error: reading templates directory: %v", err))
-	}
+	err := filepath.WalkDir(templatesDirectory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() || path == templatesDirectory {
+			return nil
+		}
 
-	for _, file := range files {
-		if file.IsDir() {
-			templates = append(templates, file.Name())
+		// Hidden directories hold templates that are still being installed
+		if strings.HasPrefix(entry.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		if isTemplateDir(path) {
+			relPath, err := filepath.Rel(templatesDirectory, path)
+			if err != nil {
+				return err
+			}
+			templates = append(templates, filepath.ToSlash(relPath))
+			return filepath.SkipDir
 		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf(color.RedString("Error: reading templates directory: %v", err))
 	}
 
 	return templates, nil
@@ -275,7 +607,7 @@ func selectTemplate(templates []string) (string, error) {
 			fmt.Print(color.CyanString(appNameArt))
 			selectedTemplate := templates[currentIndex]
 			return selectedTemplate, nil
-		case keyboard.KeyEsc:
+		case keyboard.KeyEsc, keyboard.KeyCtrlC:
 			return "", fmt.Errorf(color.RedString("Error: selection canceled."))
 		}
 
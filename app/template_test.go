@@ -0,0 +1,191 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunFileJobs(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name    string
+		jobs    int
+		failAt  int
+		cancel  bool
+		wantErr error
+	}{
+		{name: "no jobs", jobs: 0, failAt: -1},
+		{name: "fewer jobs than workers", jobs: 3, failAt: -1},
+		{name: "more jobs than workers", jobs: maxFileWorkers * 10, failAt: -1},
+		{name: "failing job", jobs: maxFileWorkers * 10, failAt: 5, wantErr: errFailed},
+		{name: "canceled", jobs: maxFileWorkers * 10, failAt: -1, cancel: true, wantErr: context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs := make([]fileJob, tt.jobs)
+			for i := range jobs {
+				jobs[i] = fileJob{src: strconv.Itoa(i)}
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+
+			var processed atomic.Int64
+			err := runFileJobs(ctx, jobs, func(job fileJob) error {
+				processed.Add(1)
+				if job.src == strconv.Itoa(tt.failAt) {
+					return errFailed
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("runFileJobs() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && processed.Load() != int64(tt.jobs) {
+				t.Errorf("processed %d jobs, want %d", processed.Load(), tt.jobs)
+			}
+		})
+	}
+}
+
+func TestCopyTemplate(t *testing.T) {
+	src := t.TempDir()
+	files := map[string]os.FileMode{
+		"go.mod":             0644,
+		"main.go":            0644,
+		"scripts/build.sh":   0755,
+		"internal/a/b/c.go":  0600,
+		".git/HEAD":          0644,
+		"docs/.github/notes": 0644,
+	}
+	for name, mode := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dest := filepath.Join(t.TempDir(), "copy")
+	if err := copyTemplate(context.Background(), src, dest); err != nil {
+		t.Fatalf("copyTemplate() error = %v", err)
+	}
+
+	for name, mode := range files {
+		info, err := os.Stat(filepath.Join(dest, filepath.FromSlash(name)))
+		skipped := filepath.Base(filepath.Dir(name)) == ".git" || filepath.Base(filepath.Dir(name)) == ".github"
+		switch {
+		case skipped && err == nil:
+			t.Errorf("%s was copied", name)
+		case skipped:
+		case err != nil:
+			t.Errorf("%s was not copied: %v", name, err)
+		case info.Mode().Perm() != mode:
+			t.Errorf("%s has mode %v, want %v", name, info.Mode().Perm(), mode)
+		}
+	}
+}
+
+func TestFindTemplates(t *testing.T) {
+	sourceDir := t.TempDir()
+	writeTestFiles(t, sourceDir, map[string]string{
+		"go.mod":          "module example.com/root\n",
+		"api/go.mod":      "module example.com/api\n",
+		"docs/index.md":   "# Docs\n",
+		".github/go.mod":  "module example.com/ci\n",
+		"cli/cmd/main.go": "package main\n",
+	})
+
+	candidates, err := findTemplates(sourceDir, "github.com/owner/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []templateCandidate{
+		{name: "github.com/owner/repo/repo", dir: sourceDir},
+		{name: "github.com/owner/repo/api", dir: filepath.Join(sourceDir, "api"), subdirectory: "api"},
+	}
+	if !reflect.DeepEqual(candidates, want) {
+		t.Errorf("findTemplates() = %+v, want %+v", candidates, want)
+	}
+}
+
+func TestResolveCollisions(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []string
+		opts       addOptions
+		want       []string
+		wantErr    bool
+	}{
+		{name: "no collision", candidates: []string{"api", "cli"}, want: []string{"api", "cli"}},
+		{name: "collision", candidates: []string{"svc", "cli"}, wantErr: true},
+		{name: "overwrite", candidates: []string{"svc", "cli"}, opts: addOptions{Overwrite: true}, want: []string{"svc", "cli"}},
+		{name: "skip", candidates: []string{"svc", "cli"}, opts: addOptions{Skip: true}, want: []string{"cli"}},
+		{name: "rename", candidates: []string{"svc"}, opts: addOptions{Rename: "svc2"}, want: []string{"svc2"}},
+		{name: "rename onto installed", candidates: []string{"api"}, opts: addOptions{Rename: "svc"}, wantErr: true},
+		{name: "rename several", candidates: []string{"api", "cli"}, opts: addOptions{Rename: "svc2"}, wantErr: true},
+	}
+
+	// Without a terminal collisions are reported instead of asked about
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	defer func(f *os.File) { os.Stdin = f }(os.Stdin)
+	os.Stdin = stdin
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestHome(t)
+			writeTestTemplate(t, "svc")
+
+			var candidates []templateCandidate
+			for _, name := range tt.candidates {
+				candidates = append(candidates, templateCandidate{name: name})
+			}
+
+			resolved, err := resolveCollisions(context.Background(), candidates, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveCollisions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, candidate := range resolved {
+				got = append(got, candidate.name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveCollisions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
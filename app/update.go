@@ -0,0 +1,300 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// updateTemplates refreshes installed templates from the sources recorded in
+// the registry. With no names every template with a known source is updated.
+// Templates follow the ref they were added with unless a new ref is given.
+func updateTemplates(ctx context.Context, names []string, ref string, force bool) error {
+	if ref != "" && len(names) == 0 {
+		return fmt.Errorf(color.RedString("Error: name the templates to move to ref '%s'", ref))
+	}
+
+	registry, err := syncRegistry(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		for name, entry := range registry.Templates {
+			if entry.Source != "" {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			fmt.Println(color.YellowString("No templates with a known source to update."))
+			return nil
+		}
+	}
+	sort.Strings(names)
+
+	installed := make([]string, 0, len(registry.Templates))
+	for id := range registry.Templates {
+		installed = append(installed, id)
+	}
+	for i, name := range names {
+		if names[i], err = resolveTemplate(installed, name); err != nil {
+			return err
+		}
+	}
+
+	// Local changes are only thrown away when asked to, and asking can take
+	// a while, so it is done before fetching and locking
+	discard, err := confirmDiscards(ctx, registry, names, force)
+	if err != nil {
+		return err
+	}
+
+	// Group templates by source and ref so that each revision is fetched only once
+	type sourceRef struct{ url, ref string }
+	bySource := map[sourceRef][]string{}
+	var sources []sourceRef
+	for _, name := range names {
+		entry := registry.Templates[name]
+		if entry.Source == "" {
+			return fmt.Errorf(color.RedString("Error: template '%s' has no recorded source", name))
+		}
+		key := sourceRef{url: entry.Source, ref: entry.Ref}
+		if ref != "" {
+			key.ref = ref
+		}
+		if _, ok := bySource[key]; !ok {
+			sources = append(sources, key)
+		}
+		bySource[key] = append(bySource[key], name)
+	}
+
+	failed := 0
+	for _, source := range sources {
+		// A single template only needs its own directory checked out
+		subdirectory := ""
+		if group := bySource[source]; len(group) == 1 {
+			subdirectory = registry.Templates[group[0]].Subdirectory
+		}
+
+		if err := updateFromSource(ctx, source.url, source.ref, subdirectory, bySource[source], discard); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			fmt.Println(err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf(color.RedString("Error: %d of %d sources failed to update", failed, len(sources)))
+	}
+	return nil
+}
+
+// confirmDiscards returns the templates among names whose local changes may
+// be overwritten: all of them with force, otherwise those the user agrees to.
+func confirmDiscards(ctx context.Context, registry *templateRegistry, names []string, force bool) (map[string]bool, error) {
+	discard := map[string]bool{}
+	for _, name := range names {
+		changes, err := checkTemplate(name, registry.Templates[name])
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case changes.empty():
+			continue
+		case force:
+			discard[name] = true
+			continue
+		case !isTerminal(os.Stdin):
+			// Skipped with a warning while updating
+			continue
+		}
+
+		fmt.Print(color.YellowString("Template '%s' was modified locally:\n%s\n", name, changes))
+		if discard[name], err = confirm(ctx, "Discard these changes?", "--force"); err != nil {
+			return nil, err
+		}
+	}
+	return discard, nil
+}
+
+// updateFromSource refreshes the templates names from the repository at url.
+// Templates modified locally are skipped unless discard allows overwriting
+// them.
+func updateFromSource(ctx context.Context, url string, ref string, subdirectory string, names []string, discard map[string]bool) error {
+	fmt.Print(color.YellowString("Fetching templates from repository: %s ...\n", url))
+
+	repoDir, source, err := fetchRepository(ctx, url, ref, subdirectory)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(repoDir)
+
+	unlock, err := lockTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Reload under the lock in case another process changed the registry
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		entry, ok := registry.Templates[name]
+		if !ok {
+			fmt.Print(color.YellowString("Warning: template '%s' was removed while updating\n", name))
+			continue
+		}
+
+		templateDir := filepath.Join(repoDir, entry.Subdirectory)
+		if !isTemplateDir(templateDir) {
+			fmt.Print(color.YellowString("Warning: template '%s' no longer exists in %s\n", name, url))
+			continue
+		}
+
+		hash, err := hashTemplate(templateDir)
+		if err != nil {
+			return err
+		}
+
+		changes, err := checkTemplate(name, entry)
+		if err != nil {
+			return err
+		}
+		if !changes.empty() && !discard[name] {
+			fmt.Print(color.YellowString("Skipping template '%s': it was modified locally:\n%s\nPass --force to discard the changes\n", name, changes))
+			continue
+		}
+
+		oldRevision := entry.Revision
+		if hash == entry.Hash && changes.empty() {
+			entry.Ref, entry.Revision, entry.Tag = source.Ref, source.Revision, source.Tag
+			fmt.Printf("Template '%s' is up to date\n", name)
+			continue
+		}
+
+		signer, err := checkSignature(name, templateDir)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		if err := replaceTemplate(ctx, templateDir, name); err != nil {
+			return err
+		}
+		if err := registry.record(name, source, entry.Subdirectory); err != nil {
+			return err
+		}
+		registry.Templates[name].SignedBy = signer
+
+		if hash == entry.Hash {
+			fmt.Print(color.GreenString("Restored '%s'\n", name))
+			continue
+		}
+		fmt.Print(color.GreenString("Updated '%s': %s → %s\n", name, shortRevision(oldRevision), shortRevision(source.Revision)))
+		printChangelog(ctx, url, oldRevision, source.Revision, entry.Subdirectory)
+	}
+
+	return registry.save()
+}
+
+// printChangelog prints the commits between two revisions that touched a
+// template. When the history can't be read, it says why.
+func printChangelog(ctx context.Context, url, from, to, subdirectory string) {
+	if from == "" || from == to {
+		return
+	}
+
+	log, err := changelog(ctx, url, from, to, subdirectory)
+	if err != nil {
+		fmt.Print(color.YellowString("  Changelog unavailable: %s\n", plainError(err)))
+		return
+	}
+	for _, line := range strings.Split(log, "\n") {
+		if line != "" {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+}
+
+// changelog returns the log of the commits between two revisions that
+// touched subdirectory. It comes from the repository cache, which is
+// fetched into when it lacks either revision, as for templates that were
+// downloaded as archives.
+func changelog(ctx context.Context, url, from, to, subdirectory string) (string, error) {
+	if !gitInstalled() {
+		return "", fmt.Errorf("git is not installed")
+	}
+	cacheDir, err := repositoryCacheDir(url)
+	if err != nil {
+		return "", err
+	}
+
+	if !hasCommits(ctx, cacheDir, from, to) {
+		unlock, err := lockPath(ctx, cacheDir+".lock", "fetching "+url)
+		if err != nil {
+			return "", err
+		}
+		defer unlock()
+
+		if err := fetchHistory(ctx, cacheDir, url, to); err != nil {
+			return "", err
+		}
+		if !hasCommits(ctx, cacheDir, from, to) {
+			return "", fmt.Errorf("revision %s is no longer in the repository", shortRevision(from))
+		}
+	}
+
+	args := []string{"log", "--oneline", "--no-decorate", fmt.Sprintf("-n%d", changelogLength), from + ".." + to}
+	if subdirectory != "" {
+		args = append(args, "--", subdirectory)
+	}
+	return gitOutput(ctx, cacheDir, args...)
+}
+
+// hasCommits reports whether the repository cache at cacheDir holds every
+// one of revisions.
+func hasCommits(ctx context.Context, cacheDir string, revisions ...string) bool {
+	if _, err := os.Stat(cacheDir); err != nil {
+		return false
+	}
+	for _, revision := range revisions {
+		if _, err := gitOutput(ctx, cacheDir, "cat-file", "-e", revision+"^{commit}"); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func shortRevision(revision string) string {
+	if revision == "" {
+		return "unknown"
+	}
+	if len(revision) > shortRevisionLength {
+		return revision[:shortRevisionLength]
+	}
+	return revision
+}
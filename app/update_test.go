@@ -0,0 +1,196 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpdateTemplates(t *testing.T) {
+	editLocally := func(t *testing.T, id string) {
+		writeTestFiles(t, templatePath(id), map[string]string{"main.go": "package edited\n"})
+	}
+	changeSource := func(t *testing.T, repo string) {
+		writeTestFiles(t, repo, map[string]string{"api/main.go": "package main\n\nfunc main() {}\n"})
+		commitTestRepo(t, repo, "add main")
+	}
+
+	tests := []struct {
+		name   string
+		change func(t *testing.T, repo string, id string)
+		force  bool
+		want   string
+		// wantUpdated is set when the registry should move to the new revision
+		wantUpdated bool
+	}{
+		{
+			name:   "unchanged",
+			change: func(t *testing.T, repo string, id string) {},
+			want:   "package main\n",
+		},
+		{
+			name:        "changed in the source",
+			change:      func(t *testing.T, repo string, id string) { changeSource(t, repo) },
+			want:        "package main\n\nfunc main() {}\n",
+			wantUpdated: true,
+		},
+		{
+			name:   "changed locally",
+			change: func(t *testing.T, repo string, id string) { editLocally(t, id) },
+			want:   "package edited\n",
+		},
+		{
+			name: "changed locally and in the source",
+			change: func(t *testing.T, repo string, id string) {
+				editLocally(t, id)
+				changeSource(t, repo)
+			},
+			want: "package edited\n",
+		},
+		{
+			name:   "changed locally with force",
+			change: func(t *testing.T, repo string, id string) { editLocally(t, id) },
+			force:  true,
+			want:   "package main\n",
+		},
+		{
+			name: "changed locally and in the source with force",
+			change: func(t *testing.T, repo string, id string) {
+				editLocally(t, id)
+				changeSource(t, repo)
+			},
+			force:       true,
+			want:        "package main\n\nfunc main() {}\n",
+			wantUpdated: true,
+		},
+	}
+
+	// Without a terminal, modified templates are skipped rather than asked about
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	defer func(f *os.File) { os.Stdin = f }(os.Stdin)
+	os.Stdin = stdin
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestHome(t)
+			ctx := context.Background()
+
+			repo := initTestRepo(t, map[string]string{
+				"api/go.mod":  "module example.com/api\n",
+				"api/main.go": "package main\n",
+			})
+			if err := downloadTemplates(ctx, repo, addOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			namespace, err := templateNamespace(repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			id := namespace + "/api"
+
+			installed := testRevision(t, repo)
+			tt.change(t, repo, id)
+			if err := updateTemplates(ctx, nil, "", tt.force); err != nil {
+				t.Fatalf("updateTemplates() error = %v", err)
+			}
+
+			got, err := os.ReadFile(filepath.Join(templatePath(id), "main.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("main.go = %q, want %q", got, tt.want)
+			}
+
+			registry, err := loadRegistry()
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantRevision := installed
+			if tt.wantUpdated {
+				wantRevision = testRevision(t, repo)
+			}
+			if entry := registry.Templates[id]; entry == nil || entry.Revision != wantRevision {
+				t.Errorf("registry entry = %+v, want revision %s", entry, wantRevision)
+			}
+		})
+	}
+}
+
+func TestChangelog(t *testing.T) {
+	repo := initTestRepo(t, map[string]string{
+		"api/go.mod": "module example.com/api\n",
+		"cli/go.mod": "module example.com/cli\n",
+	})
+	first := testRevision(t, repo)
+	writeTestFiles(t, repo, map[string]string{"api/main.go": "package main\n"})
+	commitTestRepo(t, repo, "add api main")
+	writeTestFiles(t, repo, map[string]string{"cli/main.go": "package main\n"})
+	commitTestRepo(t, repo, "add cli main")
+	last := testRevision(t, repo)
+
+	tests := []struct {
+		name         string
+		cached       bool
+		from         string
+		subdirectory string
+		want         []string
+		wantErr      bool
+	}{
+		{name: "from the cache", cached: true, from: first, subdirectory: "api", want: []string{"add api main"}},
+		{name: "without a cache", from: first, subdirectory: "cli", want: []string{"add cli main"}},
+		{name: "whole repository", from: first, want: []string{"add cli main", "add api main"}},
+		{name: "unknown revision", from: strings.Repeat("0", 40), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestHome(t)
+			ctx := context.Background()
+
+			// Archive downloads leave the cache empty
+			if tt.cached {
+				repoDir, _, err := fetchRepository(ctx, repo, "", "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				os.RemoveAll(repoDir)
+			}
+
+			log, err := changelog(ctx, repo, tt.from, last, tt.subdirectory)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("changelog() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var messages []string
+			for _, line := range strings.Split(strings.TrimSpace(log), "\n") {
+				if _, message, found := strings.Cut(line, " "); found {
+					messages = append(messages, message)
+				}
+			}
+			if strings.Join(messages, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("changelog() = %q, want %q", messages, tt.want)
+			}
+		})
+	}
+}
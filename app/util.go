@@ -15,13 +15,19 @@
 package app
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/fatih/color"
 )
@@ -46,46 +52,210 @@ func getDefaultDirectory(name string) string {
 	return templatesDir
 }
 
-func runCommand(command string, args []string, projectPath string, description string) error {
-	cmd := exec.Command(command, args...)
+func runCommand(ctx context.Context, command string, args []string, projectPath string, description string) error {
+	return runCommandEnv(ctx, command, args, nil, projectPath, description)
+}
+
+// runCommandEnv is runCommand with extra environment variables for the command.
+func runCommandEnv(ctx context.Context, command string, args []string, env []string, projectPath string, description string) error {
+	timeout := commandTimeout(description)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command, args...)
 	cmd.Dir = projectPath
-	fmt.Printf("Running %s...\n", description)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.WaitDelay = commandWaitDelay
+	setProcessGroup(cmd)
+
+	// Only the tail of the output is kept around for error reporting
+	tail := newTailBuffer(commandOutputTailLines)
+	finish := func() {}
+
+	switch {
+	case verbose:
+		fmt.Printf("Running %s...\n", description)
+		out := newPrefixWriter(os.Stdout, description)
+		finish = out.Flush
+		cmd.Stdout = io.MultiWriter(out, tail)
+	case isTerminal(os.Stdout):
+		progress := startSpinner(description)
+		finish = progress.Stop
+		cmd.Stdout = io.MultiWriter(progress, tail)
+	default:
+		fmt.Printf("Running %s...\n", description)
+		cmd.Stdout = tail
+	}
+	cmd.Stderr = cmd.Stdout
 
 	// Execute the command
-	output, err := cmd.CombinedOutput()
+	err := cmd.Run()
+	finish()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf(color.RedString("Error: %s timed out after %s\n%s", description, timeout, tail))
+	}
 	if err != nil {
-		return fmt.Errorf(color.RedString("Error: running %s: %v\n%s", description, err, output))
+		if ctx.Err() != nil {
+			return fmt.Errorf(color.RedString("Error: %s canceled", description))
+		}
+		return fmt.Errorf(color.RedString("Error: running %s: %v\n%s", description, err, tail))
 	}
 
 	fmt.Printf(color.GreenString("%s finished successfully.\n"), description)
 	return nil
 }
 
+// commandTimeout returns the configured timeout for a step, falling back to
+// the timeout configured for all steps.
+func commandTimeout(step string) time.Duration {
+	if timeout, ok := commandTimeouts[step]; ok {
+		return timeout
+	}
+	if timeout, ok := commandTimeouts[""]; ok {
+		return timeout
+	}
+	return defaultCommandTimeout
+}
+
+// parseCommandTimeouts reads timeouts given either as a bare duration that
+// applies to every step ("5m") or as a step specific one ("git fetch=2m").
+func parseCommandTimeouts(values []string) error {
+	for _, value := range values {
+		step, duration, found := strings.Cut(value, "=")
+		if !found {
+			step, duration = "", value
+		}
+
+		timeout, err := time.ParseDuration(strings.TrimSpace(duration))
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf(color.RedString("Error: invalid timeout %q", value))
+		}
+		commandTimeouts[strings.TrimSpace(step)] = timeout
+	}
+	return nil
+}
+
 // Function to initialize a Git repository in the specified project path
-func initializeGitRepository(projectPath string) error {
-	return runCommand("git", []string{"init"}, projectPath, "git init")
+func initializeGitRepository(ctx context.Context, projectPath string) error {
+	if !gitInstalled() {
+		fmt.Print(color.YellowString("Warning: git is not installed, skipping git init\n"))
+		return nil
+	}
+	return runCommand(ctx, "git", []string{"init"}, projectPath, "git init")
 }
 
 // Function to run 'go get ./...' in the specified project path
-func runGoGet(projectPath string) error {
-	return runCommand("go", []string{"get"}, projectPath, "go get")
+func runGoGet(ctx context.Context, projectPath string) error {
+	return runCommand(ctx, "go", []string{"get"}, projectPath, "go get")
 }
 
 // Function to run 'go mod tidy' in the specified project path
-func runGoModTidy(projectPath string) error {
-	return runCommand("go", []string{"mod", "tidy"}, projectPath, "go mod tidy")
+func runGoModTidy(ctx context.Context, projectPath string) error {
+	return runCommand(ctx, "go", []string{"mod", "tidy"}, projectPath, "go mod tidy")
 }
 
-// Function to clone a Git repository from the specified URL to the destination directory
-func gitClone(repoURL, destination string) error {
-	return runCommand("git", []string{"clone", repoURL, destination}, ".", "git clone")
+// gitOutput runs a git command in dir and returns its trimmed standard output.
+func gitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout("git"))
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf(color.RedString("Error: running git %s: %v\n%s", strings.Join(args, " "), err, stderr.String()))
+	}
+	return strings.TrimSpace(string(output)), nil
 }
 
-func promptProjectName() string {
+func promptProjectName(ctx context.Context) (string, error) {
 	fmt.Print(color.YellowString("Input your project name: "))
-	var projectName string
-	fmt.Scanln(&projectName)
-	return strings.TrimSpace(projectName)
+	projectName, err := readLine(ctx)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(projectName), nil
+}
+
+// lineReader hands out the lines of an input to prompts. A single goroutine
+// reads the input for the life of the process, so a prompt that gives up
+// on its context leaves the next line to the next prompt instead of losing it.
+type lineReader struct {
+	input io.Reader
+	once  sync.Once
+	lines chan lineResult
+}
+
+type lineResult struct {
+	text string
+	err  error
+}
+
+// stdin is shared by every prompt so that no buffered input is lost between
+// them.
+var stdin = &lineReader{input: os.Stdin}
+
+func (r *lineReader) read() {
+	reader := bufio.NewReader(r.input)
+	for {
+		text, err := reader.ReadString('\n')
+		r.lines <- lineResult{text, err}
+		if err != nil {
+			close(r.lines)
+			return
+		}
+	}
+}
+
+// readLine reads a line, giving up when ctx is canceled.
+func (r *lineReader) readLine(ctx context.Context) (string, error) {
+	r.once.Do(func() {
+		r.lines = make(chan lineResult)
+		go r.read()
+	})
+
+	select {
+	case <-ctx.Done():
+		return "", fmt.Errorf(color.RedString("Error: input canceled"))
+	case line, ok := <-r.lines:
+		if !ok {
+			line.err = io.EOF
+		}
+		// A final line without a newline still counts; nothing at all does not
+		if line.err != nil && line.text == "" {
+			return "", fmt.Errorf(color.RedString("Error: reading input: %v", line.err))
+		}
+		return strings.TrimSpace(line.text), nil
+	}
+}
+
+// readLine reads a line from standard input, giving up when ctx is canceled.
+func readLine(ctx context.Context) (string, error) {
+	return stdin.readLine(ctx)
+}
+
+// confirm asks a yes or no question, failing when there is no terminal to
+// ask on. flag names the flag that confirms up front.
+func confirm(ctx context.Context, question string, flag string) (bool, error) {
+	if !isTerminal(os.Stdin) {
+		return false, fmt.Errorf(color.RedString("Error: no terminal to confirm on; pass %s to confirm", flag))
+	}
+
+	fmt.Print(color.YellowString("%s [y/N] ", question))
+	answer, err := readLine(ctx)
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
 }
 
 func shouldSkipFile(fileName string) bool {
@@ -93,6 +263,16 @@ func shouldSkipFile(fileName string) bool {
 	return ignoredFiles[fileName]
 }
 
+// shouldSkipProjectFile reports whether a file of a template, given by its
+// path relative to the template root, is left out of generated projects.
+func shouldSkipProjectFile(relPath string) bool {
+	switch relPath {
+	case manifestFileName, signatureFileName, ignoreFileName:
+		return true
+	}
+	return shouldSkipFile(filepath.Base(relPath))
+}
+
 func shouldSkipDir(dirName string) bool {
 	skipDirs := map[string]bool{
 		".git":    true,
@@ -114,6 +294,13 @@ func isValidProjectName(name string) bool {
 
 	return true
 }
+
+// isValidTemplateName reports whether name can be used as the directory name
+// of an installed template.
+func isValidTemplateName(name string) bool {
+	return name != "" && filepath.IsLocal(name) && !strings.ContainsAny(name, `/\`) && !strings.HasPrefix(name, ".")
+}
+
 func isValidCharacter(char rune) bool {
 
 	return (char >= 'a' && char <= 'z') ||
@@ -151,6 +338,38 @@ func isGoModule(dir string) bool {
 	return true // go.mod file exists
 }
 
+// isTemplateDir reports whether dir holds a template: a Go module, or one
+// whose go.mod is itself templated.
+func isTemplateDir(dir string) bool {
+	if isGoModule(dir) {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(dir, "go.mod"+templateFileSuffix))
+	return err == nil
+}
+
+// splitRepoRef splits a repository URL of the form <url>@<ref> into the URL
+// and the ref. Only an "@" after the start of the repository path counts, so
+// that user names as in git@github.com:owner/repo are left alone.
+func splitRepoRef(repoURL string) (string, string) {
+	pathStart := 0
+	if i := strings.Index(repoURL, "://"); i >= 0 {
+		slash := strings.Index(repoURL[i+3:], "/")
+		if slash < 0 {
+			return repoURL, ""
+		}
+		pathStart = i + 3 + slash
+	} else if i := strings.Index(repoURL, ":"); i >= 0 {
+		pathStart = i
+	}
+
+	at := strings.LastIndex(repoURL, "@")
+	if at <= pathStart || at == len(repoURL)-1 {
+		return repoURL, ""
+	}
+	return repoURL[:at], repoURL[at+1:]
+}
+
 func extractRepoNameFromURL(repoURL string) (string, error) {
 	// Parse the repository URL
 	parsedURL, err := url.Parse(repoURL)
@@ -0,0 +1,136 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitRepoRef(t *testing.T) {
+	tests := []struct {
+		url, wantURL, wantRef string
+	}{
+		{url: "https://github.com/acme/templates", wantURL: "https://github.com/acme/templates"},
+		{url: "https://github.com/acme/templates@v1.2.0", wantURL: "https://github.com/acme/templates", wantRef: "v1.2.0"},
+		{url: "https://user@github.com/acme/templates", wantURL: "https://user@github.com/acme/templates"},
+		{url: "https://user@github.com/acme/templates@main", wantURL: "https://user@github.com/acme/templates", wantRef: "main"},
+		{url: "git@github.com:acme/templates.git", wantURL: "git@github.com:acme/templates.git"},
+		{url: "git@github.com:acme/templates.git@feature/x", wantURL: "git@github.com:acme/templates.git", wantRef: "feature/x"},
+		{url: "https://github.com/acme/templates@", wantURL: "https://github.com/acme/templates@"},
+		{url: "/srv/templates@v2", wantURL: "/srv/templates", wantRef: "v2"},
+	}
+
+	for _, tt := range tests {
+		gotURL, gotRef := splitRepoRef(tt.url)
+		if gotURL != tt.wantURL || gotRef != tt.wantRef {
+			t.Errorf("splitRepoRef(%q) = %q, %q, want %q, %q", tt.url, gotURL, gotRef, tt.wantURL, tt.wantRef)
+		}
+	}
+}
+
+func TestDownloadTemplatesRef(t *testing.T) {
+	repo := initTestRepo(t, map[string]string{"go.mod": "module example.com/v1\n"})
+	runTestGit(t, repo, "tag", "v1")
+	tagged := testRevision(t, repo)
+	writeTestFiles(t, repo, map[string]string{"go.mod": "module example.com/v2\n"})
+	commitTestRepo(t, repo, "move to v2")
+	head := testRevision(t, repo)
+
+	tests := []struct {
+		name         string
+		url          string
+		ref          string
+		wantModule   string
+		wantRevision string
+		wantTag      string
+		wantRef      string
+		wantErr      bool
+	}{
+		{name: "default branch", url: repo, wantModule: "module example.com/v2\n", wantRevision: head},
+		{name: "tag", url: repo, ref: "v1", wantModule: "module example.com/v1\n", wantRevision: tagged, wantTag: "v1", wantRef: "v1"},
+		{name: "ref in the URL", url: repo + "@v1", wantModule: "module example.com/v1\n", wantRevision: tagged, wantTag: "v1", wantRef: "v1"},
+		{name: "branch", url: repo, ref: "main", wantModule: "module example.com/v2\n", wantRevision: head, wantRef: "main"},
+		// A commit is fetched on its own, without the tags pointing at it
+		{name: "commit", url: repo, ref: tagged, wantModule: "module example.com/v1\n", wantRevision: tagged, wantRef: tagged},
+		{name: "conflicting refs", url: repo + "@v1", ref: "main", wantErr: true},
+		{name: "unknown ref", url: repo, ref: "v9", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestHome(t)
+
+			err := downloadTemplates(context.Background(), tt.url, addOptions{Ref: tt.ref})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("downloadTemplates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			namespace, err := templateNamespace(repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			name := namespace + "/" + filepath.Base(repo)
+			module, err := os.ReadFile(filepath.Join(templatePath(name), "go.mod"))
+			if err != nil || string(module) != tt.wantModule {
+				t.Errorf("installed go.mod = %q, %v, want %q", module, err, tt.wantModule)
+			}
+
+			registry, err := loadRegistry()
+			if err != nil {
+				t.Fatal(err)
+			}
+			entry := registry.Templates[name]
+			if entry == nil || entry.Revision != tt.wantRevision || entry.Tag != tt.wantTag || entry.Ref != tt.wantRef {
+				t.Errorf("registry entry = %+v, want revision %s, tag %q and ref %q", entry, tt.wantRevision, tt.wantTag, tt.wantRef)
+			}
+		})
+	}
+}
+
+func TestLineReader(t *testing.T) {
+	input, w := io.Pipe()
+	reader := &lineReader{input: input}
+
+	// A prompt that gives up does not take the line meant for the next one
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := reader.readLine(ctx); err == nil {
+		t.Fatal("readLine() with a canceled context succeeded")
+	}
+
+	go func() {
+		io.WriteString(w, "first\n  second  \nlast")
+		w.Close()
+	}()
+
+	for _, want := range []string{"first", "second", "last"} {
+		got, err := reader.readLine(context.Background())
+		if err != nil || got != want {
+			t.Errorf("readLine() = %q, %v, want %q", got, err, want)
+		}
+	}
+	for range 2 {
+		if _, err := reader.readLine(context.Background()); err == nil {
+			t.Error("readLine() at the end of the input succeeded")
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// templateChanges are the differences between an installed template and the
+// files recorded for it when it was installed.
+type templateChanges struct {
+	Modified []string
+	Missing  []string
+	Extra    []string
+	// Unrecorded is set for templates installed before figo recorded
+	// individual files, whose content no longer matches their hash
+	Unrecorded bool
+}
+
+func (c templateChanges) empty() bool {
+	return len(c.Modified) == 0 && len(c.Missing) == 0 && len(c.Extra) == 0 && !c.Unrecorded
+}
+
+// String lists the changes, one file per line.
+func (c templateChanges) String() string {
+	var lines []string
+	for _, path := range c.Modified {
+		lines = append(lines, "  modified: "+path)
+	}
+	for _, path := range c.Missing {
+		lines = append(lines, "  missing:  "+path)
+	}
+	for _, path := range c.Extra {
+		lines = append(lines, "  extra:    "+path)
+	}
+	if c.Unrecorded {
+		lines = append(lines, "  content differs from when it was installed")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// checkTemplate compares the installed template id with its registry entry.
+// Entries from before files were recorded get their files filled in when
+// the template is unchanged.
+func checkTemplate(id string, entry *registryEntry) (templateChanges, error) {
+	var changes templateChanges
+
+	files, err := templateFiles(templatePath(id))
+	if err != nil {
+		return changes, err
+	}
+
+	if entry.Files == nil {
+		if digestFiles(files) == entry.Hash {
+			entry.Files = files
+		} else {
+			changes.Unrecorded = true
+		}
+		return changes, nil
+	}
+
+	for path, hash := range entry.Files {
+		current, ok := files[path]
+		switch {
+		case !ok:
+			changes.Missing = append(changes.Missing, path)
+		case current != hash:
+			changes.Modified = append(changes.Modified, path)
+		}
+	}
+	for path := range files {
+		if _, ok := entry.Files[path]; !ok {
+			changes.Extra = append(changes.Extra, path)
+		}
+	}
+
+	sort.Strings(changes.Modified)
+	sort.Strings(changes.Missing)
+	sort.Strings(changes.Extra)
+	return changes, nil
+}
+
+// verifyTemplates checks installed templates, all of them when no names are
+// given, against the files recorded when they were installed. It fails when
+// any template was changed.
+func verifyTemplates(ctx context.Context, names []string) error {
+	unlock, err := lockTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	installed, err := installedTemplates()
+	if err != nil {
+		return err
+	}
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		names = installed
+	}
+
+	changed := 0
+	for _, name := range names {
+		id, err := resolveTemplate(installed, name)
+		if err != nil {
+			return err
+		}
+
+		entry, ok := registry.Templates[id]
+		if !ok {
+			fmt.Print(color.YellowString("%s: not recorded, cannot verify\n", id))
+			continue
+		}
+		if entry.editable() {
+			fmt.Print(color.YellowString("%s: has no source, nothing to verify\n", id))
+			continue
+		}
+
+		changes, err := checkTemplate(id, entry)
+		if err != nil {
+			return err
+		}
+		if changes.empty() {
+			fmt.Print(color.GreenString("%s: ok\n", id))
+			continue
+		}
+
+		changed++
+		fmt.Print(color.RedString("%s: modified\n", id))
+		fmt.Println(changes)
+	}
+
+	// Save the files filled in for older entries
+	if err := registry.save(); err != nil {
+		return err
+	}
+
+	if changed > 0 {
+		return fmt.Errorf(color.RedString("Error: %d template(s) were modified locally; restore them with 'figo templates update --force <name>'", changed))
+	}
+	return nil
+}
+
+// checkTemplateIntegrity refuses templates that were changed since they were
+// installed, unless allowModified is set, in which case it only warns. It
+// reports whether the template is signed by a trusted key and unchanged.
+func checkTemplateIntegrity(id string, allowModified bool) (bool, error) {
+	registry, err := loadRegistry()
+	if err != nil {
+		return false, err
+	}
+
+	entry, ok := registry.Templates[id]
+	if config.RequireSignatures && (!ok || entry.SignedBy == "") {
+		return false, fmt.Errorf(color.RedString("Error: template '%s' is not signed by a trusted publisher, and signatures are required", id))
+	}
+	if !ok {
+		fmt.Print(color.YellowString("Warning: template '%s' is not recorded and cannot be verified\n", id))
+		return false, nil
+	}
+	if entry.editable() {
+		return false, nil
+	}
+
+	changes, err := checkTemplate(id, entry)
+	if err != nil {
+		return false, err
+	}
+	if changes.empty() {
+		return entry.SignedBy != "", nil
+	}
+
+	if allowModified {
+		fmt.Print(color.YellowString("Warning: template '%s' was modified locally:\n%s\n", id, changes))
+		return false, nil
+	}
+	return false, fmt.Errorf(color.RedString("Error: template '%s' was modified locally:\n%s\nRestore it with 'figo templates update --force %s', or pass --allow-modified to use it anyway", id, changes, id))
+}
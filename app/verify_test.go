@@ -0,0 +1,149 @@
+// Copyright 2024 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// recordTestTemplate installs a template with files and records it in the
+// registry.
+func recordTestTemplate(t *testing.T, id string, files map[string]string) {
+	t.Helper()
+
+	writeTestTemplate(t, id)
+	writeTestFiles(t, templatePath(id), files)
+
+	registry, err := loadRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := templateSource{URL: "https://github.com/acme/templates", Revision: "abc123"}
+	if err := registry.record(id, source, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.save(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckTemplate(t *testing.T) {
+	tests := []struct {
+		name       string
+		unrecorded bool
+		change     func(dir string) error
+		want       templateChanges
+	}{
+		{name: "unchanged"},
+		{
+			name: "modified",
+			change: func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, "main.go"), []byte("package other\n"), 0644)
+			},
+			want: templateChanges{Modified: []string{"main.go"}},
+		},
+		{
+			name:   "missing",
+			change: func(dir string) error { return os.Remove(filepath.Join(dir, "main.go")) },
+			want:   templateChanges{Missing: []string{"main.go"}},
+		},
+		{
+			name: "extra",
+			change: func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, "extra.go"), []byte("package main\n"), 0644)
+			},
+			want: templateChanges{Extra: []string{"extra.go"}},
+		},
+		{name: "unrecorded files", unrecorded: true},
+		{
+			name:       "unrecorded files modified",
+			unrecorded: true,
+			change:     func(dir string) error { return os.Remove(filepath.Join(dir, "main.go")) },
+			want:       templateChanges{Unrecorded: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestHome(t)
+			recordTestTemplate(t, "local/api", map[string]string{"main.go": "package main\n"})
+
+			registry, err := loadRegistry()
+			if err != nil {
+				t.Fatal(err)
+			}
+			entry := registry.Templates["local/api"]
+			recorded := entry.Files
+			if tt.unrecorded {
+				entry.Files = nil
+			}
+			if tt.change != nil {
+				if err := tt.change(templatePath("local/api")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			changes, err := checkTemplate("local/api", entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(changes, tt.want) {
+				t.Errorf("checkTemplate() = %+v, want %+v", changes, tt.want)
+			}
+			// Unchanged templates get their files recorded
+			if tt.unrecorded && tt.change == nil && !reflect.DeepEqual(entry.Files, recorded) {
+				t.Errorf("checkTemplate() recorded files %v, want %v", entry.Files, recorded)
+			}
+		})
+	}
+}
+
+func TestVerifyTemplates(t *testing.T) {
+	useTestHome(t)
+	ctx := context.Background()
+
+	recordTestTemplate(t, "local/api", map[string]string{"main.go": "package main\n"})
+	recordTestTemplate(t, "local/cli", map[string]string{"main.go": "package main\n"})
+	writeTestTemplate(t, "local/unrecorded")
+
+	if err := verifyTemplates(ctx, nil); err != nil {
+		t.Fatalf("verifyTemplates() of unchanged templates error = %v", err)
+	}
+
+	writeTestFiles(t, templatePath("local/cli"), map[string]string{"main.go": "package cli\n"})
+	if err := verifyTemplates(ctx, nil); err == nil {
+		t.Error("verifyTemplates() passed a modified template")
+	}
+	if err := verifyTemplates(ctx, []string{"api"}); err != nil {
+		t.Errorf("verifyTemplates() of an unchanged template error = %v", err)
+	}
+
+	if trusted, err := checkTemplateIntegrity("local/api", false); err != nil || trusted {
+		t.Errorf("checkTemplateIntegrity() of an unsigned template = %v, %v", trusted, err)
+	}
+	if _, err := checkTemplateIntegrity("local/cli", false); err == nil {
+		t.Error("checkTemplateIntegrity() accepted a modified template")
+	}
+	if _, err := checkTemplateIntegrity("local/cli", true); err != nil {
+		t.Errorf("checkTemplateIntegrity() with allowModified error = %v", err)
+	}
+	if _, err := checkTemplateIntegrity("local/unrecorded", false); err != nil {
+		t.Errorf("checkTemplateIntegrity() of an unrecorded template error = %v", err)
+	}
+}
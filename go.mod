@@ -4,14 +4,12 @@ go 1.22.0
 
 require (
 	github.com/fatih/color v1.16.0
+	github.com/mattn/go-isatty v0.0.20
 	github.com/urfave/cli/v2 v2.27.1
+	golang.org/x/sys v0.19.0
 )
 
-require (
-	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
-	golang.org/x/sys v0.19.0 // indirect
-)
+require github.com/mattn/go-colorable v0.1.13 // indirect
 
 require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.4 // indirect
@@ -15,15 +15,27 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/itpey/figo/app"
 )
 
 func main() {
+	// Cancel running commands on SIGINT/SIGTERM so that they can clean up after
+	// themselves; a second signal terminates immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	figo := app.Create()
-	err := figo.Run(os.Args)
+	err := figo.RunContext(ctx, os.Args)
+	stop()
 	if err != nil {
 		log.Fatal(err)
 	}